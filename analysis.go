@@ -0,0 +1,129 @@
+package balancer
+
+import (
+	"sort"
+)
+
+// AnalysisSamples is the number of hashes the estimates of Analyzer are sampled over.
+var AnalysisSamples = 1 << 16
+
+// Analyzer is implemented by the hash balancers, to plan an Update,
+// e.g. in a pre-deploy check of the cache tier:
+//
+//	r := lb.(balancer.Analyzer).Remap(next)
+//	if r.Moved > 0.25 {
+//		log.Fatalf("%.0f%% of the keys would move", 100*r.Moved)
+//	}
+//
+// The keyspace is owned regardless of the availability of the choices.
+type Analyzer interface {
+	// Ownership returns the fraction of the keyspace owned by each choice.
+	Ownership() Ownership
+
+	// Remap returns the fraction of the keyspace that would move to another owner
+	// by an Update with the choices, and between which choices.
+	// The balancer is not changed.
+	Remap(choices []*Choice) Remap
+}
+
+// Ownership is the fraction of the keyspace owned by each choice.
+type Ownership struct {
+	// Shares in the order of the choices.
+	Shares []Share
+
+	// Exact is false for estimates over AnalysisSamples hashes.
+	Exact bool
+}
+
+type Share struct {
+	Choice   *Choice
+	Fraction float64
+}
+
+// Remap is the fraction of the keyspace moving to another owner,
+// estimated over Samples hashes.
+type Remap struct {
+	Moved float64
+
+	// Flows of the keys moving, the largest first.
+	// From is nil without current choices, To is nil without new ones.
+	Flows []Flow
+
+	Samples int
+}
+
+type Flow struct {
+	From     *Choice
+	To       *Choice
+	Fraction float64
+}
+
+// ownerFunc returns the owner of a hash, nil without choices.
+type ownerFunc func(hash uint64) *Choice
+
+// evenOwnership returns the ownership of a balancer spreading the keyspace evenly over the items.
+func evenOwnership(items []*Choice) Ownership {
+	o := Ownership{Shares: make([]Share, len(items)), Exact: true}
+	for i, c := range items {
+		o.Shares[i] = Share{Choice: c, Fraction: 1 / float64(len(items))}
+	}
+	return o
+}
+
+// sampleOwnership estimates the ownership over AnalysisSamples hashes.
+func sampleOwnership(items []*Choice, owner ownerFunc) Ownership {
+	count := make(map[*Choice]int, len(items))
+	n := analysisSamples()
+	for i := 0; i < n; i++ {
+		count[owner(sampleHash(i))]++
+	}
+	o := Ownership{Shares: make([]Share, len(items))}
+	for i, c := range items {
+		o.Shares[i] = Share{Choice: c, Fraction: float64(count[c]) / float64(n)}
+	}
+	return o
+}
+
+// sampleRemap estimates the keys moving from the owners of from to the owners of to.
+func sampleRemap(from, to ownerFunc) Remap {
+	type move struct {
+		from, to *Choice
+	}
+	n := analysisSamples()
+	count := make(map[move]int)
+	var order []move
+	moved := 0
+	for i := 0; i < n; i++ {
+		hash := sampleHash(i)
+		m := move{from(hash), to(hash)}
+		if m.from == nil && m.to == nil || m.from != nil && m.to != nil && sameChoice(m.from, m.to) {
+			continue
+		}
+		if count[m] == 0 {
+			order = append(order, m)
+		}
+		count[m]++
+		moved++
+	}
+
+	r := Remap{Moved: float64(moved) / float64(n), Flows: make([]Flow, len(order)), Samples: n}
+	for i, m := range order {
+		r.Flows[i] = Flow{From: m.from, To: m.to, Fraction: float64(count[m]) / float64(n)}
+	}
+	sort.SliceStable(r.Flows, func(i, j int) bool {
+		return r.Flows[i].Fraction > r.Flows[j].Fraction
+	})
+	return r
+}
+
+func analysisSamples() int {
+	if AnalysisSamples <= 0 {
+		return 1 << 16
+	}
+	return AnalysisSamples
+}
+
+// sampleHash returns the i-th hash of the samples, spread over the keyspace.
+func sampleHash(i int) uint64 {
+	return mix64(uint64(i) + 1)
+}
@@ -0,0 +1,131 @@
+package balancer
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestOwnership(t *testing.T) {
+	for _, m := range []Mode{ConsistentHash, MultiProbeConsistentHash, AnchorHash, RendezvousHash} {
+		choices := genNodes(10)
+		a := New(m, choices).(Analyzer)
+		o := a.Ownership()
+		if o.Exact != (m == ConsistentHash || m == AnchorHash) || len(o.Shares) != 10 {
+			t.Fatalf("%s ownership wrong: %+v", m, o)
+		}
+		total := 0.0
+		for i, s := range o.Shares {
+			if s.Choice != choices[i] || s.Fraction < 0.07 || s.Fraction > 0.13 {
+				t.Fatalf("%s ownership wrong: %v %f", m, s.Choice.Item, s.Fraction)
+			}
+			total += s.Fraction
+		}
+		if math.Abs(total-1) > 1e-9 {
+			t.Fatalf("%s ownership expected a total of 1, actual %f", m, total)
+		}
+
+		// the estimate of a keyed balancer matches its keys
+		count := make(map[interface{}]int)
+		lb := New(m, choices)
+		for i := 0; i < 100000; i++ {
+			count[lb.Select(strconv.Itoa(i))]++
+		}
+		for _, s := range o.Shares {
+			if math.Abs(float64(count[s.Choice.Item])/100000-s.Fraction) > 0.01 {
+				t.Fatalf("%s ownership of %v expected %f, actual %f", m, s.Choice.Item, s.Fraction, float64(count[s.Choice.Item])/100000)
+			}
+		}
+
+		if o := New(m, nil).(Analyzer).Ownership(); len(o.Shares) != 0 {
+			t.Fatalf("%s ownership expected no shares", m)
+		}
+	}
+}
+
+func TestRemap(t *testing.T) {
+	for _, m := range []Mode{ConsistentHash, MultiProbeConsistentHash, AnchorHash, RendezvousHash} {
+		choices := genNodes(10)
+		lb := New(m, choices)
+		a := lb.(Analyzer)
+
+		// an item added in the middle of the sort order, 10.0.0.1 < 10.0.0.10 < 10.0.0.2: only keys move to it,
+		// the jump hash of ConsistentHash only keeps that for an item sorted last
+		if m != ConsistentHash {
+			r := a.Remap(append(genNodes(10), &Choice{Item: "10.0.0.10", Weight: 1}))
+			if r.Moved < 0.06 || r.Moved > 0.12 {
+				t.Fatalf("%s remap wrong: %f", m, r.Moved)
+			}
+			for _, f := range r.Flows {
+				if f.To.Item != "10.0.0.10" {
+					t.Fatalf("%s remap moved keys from %v to %v", m, f.From.Item, f.To.Item)
+				}
+			}
+		}
+
+		// an item added: a tenth of the keys move to it
+		r := a.Remap(append(genNodes(10), &Choice{Item: "node-new", Weight: 1}))
+		if r.Moved < 0.06 || r.Moved > 0.12 || r.Samples != AnalysisSamples {
+			t.Fatalf("%s remap wrong: %f", m, r.Moved)
+		}
+		total := 0.0
+		for _, f := range r.Flows {
+			if f.To.Item != "node-new" {
+				t.Fatalf("%s remap moved keys from %v to %v", m, f.From.Item, f.To.Item)
+			}
+			total += f.Fraction
+		}
+		if math.Abs(total-r.Moved) > 1e-9 {
+			t.Fatalf("%s remap flows wrong: %f %f", m, total, r.Moved)
+		}
+		for i := 1; i < len(r.Flows); i++ {
+			if r.Flows[i].Fraction > r.Flows[i-1].Fraction {
+				t.Fatalf("%s remap expected the largest flows first", m)
+			}
+		}
+
+		// the balancer is not changed
+		if len(lb.(ChoiceLister).Choices()) != 10 {
+			t.Fatalf("%s remap changed the balancer", m)
+		}
+
+		// the same items: no keys move
+		if r := a.Remap(genNodes(10)); r.Moved != 0 || len(r.Flows) != 0 {
+			t.Fatalf("%s remap expected no keys to move, actual %f", m, r.Moved)
+		}
+
+		// the estimate matches the keys
+		next := append([]*Choice(nil), choices[:3]...)
+		next = append(next, choices[4:]...)
+		r = a.Remap(next)
+		before := make([]interface{}, 100000)
+		for i := range before {
+			before[i] = lb.Select(strconv.Itoa(i))
+		}
+		lb.Update(next)
+		moved := 0
+		for i := range before {
+			if lb.Select(strconv.Itoa(i)) != before[i] {
+				moved++
+			}
+		}
+		if math.Abs(float64(moved)/100000-r.Moved) > 0.01 {
+			t.Fatalf("%s remap expected %f, actual %f", m, r.Moved, float64(moved)/100000)
+		}
+		if m != ConsistentHash {
+			// minimal disruption: only the keys of the removed item move
+			for _, f := range r.Flows {
+				if f.From != choices[3] {
+					t.Fatalf("%s remap moved keys from %v", m, f.From.Item)
+				}
+			}
+		}
+
+		if r := a.Remap(nil); r.Moved != 1 || r.Flows[0].To != nil {
+			t.Fatalf("%s remap expected all keys to move", m)
+		}
+		if r := New(m, nil).(Analyzer).Remap(choices); r.Moved != 1 || r.Flows[0].From != nil {
+			t.Fatalf("%s remap expected all keys to move", m)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package balancer
+
+import (
+	"strconv"
+
+	"github.com/shibingli/load-balancer/hasher"
+)
+
+// DefaultAnchorCapacity is the number of buckets of AnchorHash, the most items it holds
+// before it has to grow, which remaps all keys.
+const DefaultAnchorCapacity = 1 << 10
+
+// AnchorHash is a consistent hash supporting the removal and re-addition of any item:
+// only the keys of a removed item move, and only keys moving to an added item move.
+// The memory is four integers per bucket of the capacity.
+// Ref: https://arxiv.org/abs/1812.09674
+type anchorHash struct {
+	capacity int
+	a        *anchor
+	// bucket -> choice, nil if the bucket is not in use
+	buckets []*Choice
+	// identities of the items, and their buckets
+	itemIDs []string
+	ids     map[string]uint32
+	hashBalancer
+}
+
+func NewAnchorHash(choices ...*Choice) (lb *anchorHash) {
+	return NewAnchorHashWithCapacity(DefaultAnchorCapacity, choices...)
+}
+
+// NewAnchorHashWithCapacity create an AnchorHash balancer of capacity buckets.
+// Updating to more items than the capacity doubles it, which remaps all keys.
+func NewAnchorHashWithCapacity(capacity int, choices ...*Choice) (lb *anchorHash) {
+	if capacity < 1 {
+		capacity = 1
+	}
+	lb = &anchorHash{capacity: capacity}
+	lb.placement = lb
+	lb.Update(choices)
+	return
+}
+
+func (b *anchorHash) chooseN(n int, hash uint64) []*Choice {
+	c, err := b.choose(hash)
+	if err != nil {
+		return nil
+	}
+	choices := make([]*Choice, 1, n)
+	choices[0] = c
+	seen := map[*Choice]struct{}{c: {}}
+
+	// the probes of choose, then the items in order
+	for i := 0; i < 4*b.count && len(choices) < n; i++ {
+		hash = hash*0x9e3779b97f4a7c15 + 1
+		c = b.owner(hash)
+		if _, ok := seen[c]; !ok && c.Available() {
+			seen[c] = struct{}{}
+			choices = append(choices, c)
+		}
+	}
+	for _, c = range b.items {
+		if len(choices) == n {
+			break
+		}
+		if _, ok := seen[c]; !ok && c.Available() {
+			seen[c] = struct{}{}
+			choices = append(choices, c)
+		}
+	}
+	return choices
+}
+
+// choose returns the owner of the hash, or a consistent fallback if the owner is not available.
+func (b *anchorHash) choose(hash uint64) (*Choice, error) {
+	c := b.owner(hash)
+	if c.Available() {
+		return c, nil
+	}
+
+	for i := 1; i < b.count; i++ {
+		hash = hash*0x9e3779b97f4a7c15 + 1
+		if c = b.owner(hash); c.Available() {
+			return c, nil
+		}
+	}
+	for _, c = range b.items {
+		if c.Available() {
+			return c, nil
+		}
+	}
+	return nil, unavailable(b.items)
+}
+
+func (b *anchorHash) owner(hash uint64) *Choice {
+	if b.count == 0 {
+		return nil
+	}
+	return b.buckets[b.a.get(hash)]
+}
+
+// SetHasher replaces the hasher of the keys.
+// Like Update, it must not be called concurrently with a selection.
+func (b *anchorHash) SetHasher(h hasher.Hasher) {
+	b.hasher = h
+}
+
+// Ownership returns the exact ownership of AnchorHash: the keyspace is spread evenly.
+func (b *anchorHash) Ownership() Ownership {
+	return evenOwnership(b.items)
+}
+
+// Remap estimates the keys moving by an Update with the choices, see Analyzer.
+// The buckets of the removed items are taken into account, like for Update.
+func (b *anchorHash) Remap(choices []*Choice) Remap {
+	next := b.clone()
+	_ = next.UpdateE(choices)
+	return sampleRemap(b.owner, next.owner)
+}
+
+// clone returns a copy of b that can be updated without changing b.
+func (b *anchorHash) clone() *anchorHash {
+	c := *b
+	c.placement = &c
+	if b.a != nil {
+		a := *b.a
+		a.a = append([]uint32(nil), a.a...)
+		a.w = append([]uint32(nil), a.w...)
+		a.l = append([]uint32(nil), a.l...)
+		a.k = append([]uint32(nil), a.k...)
+		a.r = append([]uint32(nil), a.r...)
+		c.a = &a
+	}
+	c.buckets = append([]*Choice(nil), b.buckets...)
+	c.ids = make(map[string]uint32, len(b.ids))
+	for id, bucket := range b.ids {
+		c.ids[id] = bucket
+	}
+	return &c
+}
+
+func (b *anchorHash) Name() string {
+	return "AnchorHash"
+}
+
+func (b *anchorHash) Update(choices []*Choice) bool {
+	return b.UpdateE(choices) == nil
+}
+
+// UpdateE removes the buckets of the items that are gone, then adds the new items,
+// each taking the bucket removed last.
+func (b *anchorHash) UpdateE(choices []*Choice) error {
+	items, n := cleanChoices(choices)
+	itemIDs := anchorIDs(items)
+
+	if b.a == nil || n > b.capacity {
+		for b.capacity < n {
+			b.capacity *= 2
+		}
+		b.a = newAnchor(b.capacity, 0)
+		b.buckets = make([]*Choice, b.capacity)
+		b.ids = make(map[string]uint32, n)
+		b.itemIDs = nil
+	}
+
+	keep := make(map[string]struct{}, n)
+	for _, id := range itemIDs {
+		keep[id] = struct{}{}
+	}
+	// removed in reverse order, so that adding them back in order restores their buckets
+	for i := len(b.itemIDs) - 1; i >= 0; i-- {
+		id := b.itemIDs[i]
+		if _, ok := keep[id]; !ok {
+			bucket := b.ids[id]
+			b.a.remove(bucket)
+			b.buckets[bucket] = nil
+			delete(b.ids, id)
+		}
+	}
+	for i, c := range items {
+		bucket, ok := b.ids[itemIDs[i]]
+		if !ok {
+			bucket = b.a.add()
+			b.ids[itemIDs[i]] = bucket
+		}
+		b.buckets[bucket] = c
+	}
+
+	b.items, b.count, b.itemIDs = items, n, itemIDs
+	b.labels = &labelCounts{}
+	if n == 0 {
+		return ErrNoChoices
+	}
+	return nil
+}
+
+// anchorIDs returns the identities of the items, repeated items are told apart by their occurrence.
+func anchorIDs(items []*Choice) []string {
+	ids := make([]string, len(items))
+	seen := make(map[string]int, len(items))
+	for i := range items {
+		id := items[i].Key()
+		if n := seen[id]; n > 0 {
+			seen[id]++
+			id += "\x00" + strconv.Itoa(n)
+		} else {
+			seen[id] = 1
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// anchor is the bucket state of AnchorHash: a fixed range of capacity buckets of which
+// the first working ones are in use.
+type anchor struct {
+	// A: for a removed bucket, the number of working buckets after its removal, 0 if in use
+	a []uint32
+	// W: the working buckets, W[0:n]
+	w []uint32
+	// L: the position of each bucket in W
+	l []uint32
+	// K: the bucket replacing each removed bucket
+	k []uint32
+	// R: the removed buckets, the last one removed on top
+	r []uint32
+	n uint32
+}
+
+func newAnchor(capacity, working int) *anchor {
+	s := &anchor{
+		a: make([]uint32, capacity),
+		w: make([]uint32, capacity),
+		l: make([]uint32, capacity),
+		k: make([]uint32, capacity),
+		r: make([]uint32, 0, capacity),
+		n: uint32(working),
+	}
+	for b := capacity - 1; b >= working; b-- {
+		s.r = append(s.r, uint32(b))
+		s.a[b] = uint32(b)
+	}
+	for b := 0; b < capacity; b++ {
+		s.w[b], s.l[b], s.k[b] = uint32(b), uint32(b), uint32(b)
+	}
+	return s
+}
+
+// get returns the working bucket of the hash.
+func (s *anchor) get(hash uint64) uint32 {
+	b := uint32(mix64(hash) % uint64(len(s.a)))
+	for s.a[b] > 0 {
+		h := uint32(mix64(hash^mix64(uint64(b)+1)) % uint64(s.a[b]))
+		for s.a[h] >= s.a[b] {
+			h = s.k[h]
+		}
+		b = h
+	}
+	return b
+}
+
+// add takes the bucket removed last back into use.
+func (s *anchor) add() uint32 {
+	b := s.r[len(s.r)-1]
+	s.r = s.r[:len(s.r)-1]
+	s.a[b] = 0
+	s.l[s.w[s.n]] = s.n
+	s.w[s.l[b]] = b
+	s.k[b] = b
+	s.n++
+	return b
+}
+
+// remove takes the working bucket b out of use.
+func (s *anchor) remove(b uint32) {
+	s.r = append(s.r, b)
+	s.n--
+	s.a[b] = s.n
+	s.w[s.l[b]] = s.w[s.n]
+	s.l[s.w[s.n]] = s.l[b]
+	s.k[b] = s.w[s.n]
+}
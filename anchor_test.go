@@ -0,0 +1,164 @@
+package balancer
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestAnchorHash(t *testing.T) {
+	lb := NewAnchorHash()
+	if item := lb.Select("a"); item != nil {
+		t.Fatalf("anchor expected nil, actual %s", item)
+	}
+	if _, err := lb.SelectE("a"); err != ErrNoChoices {
+		t.Fatalf("anchor expected ErrNoChoices, actual %v", err)
+	}
+
+	lb = NewAnchorHash(NewChoicesSlice([]string{"A", "B", "C", "D"})...)
+	if _, err := lb.SelectE(); err != ErrKeyRequired {
+		t.Fatalf("anchor expected ErrKeyRequired, actual %v", err)
+	}
+	count := make(map[interface{}]int)
+	for i := 0; i < 1000; i++ {
+		key := "192.168.1." + strconv.Itoa(i)
+		item := lb.Select(key)
+		if lb.Select(key) != item || lb.SelectBytes([]byte(key)) != item {
+			t.Fatal("anchor expected the same item")
+		}
+		count[item]++
+	}
+	if len(count) != 4 {
+		t.Fatalf("anchor wrong: %v", count)
+	}
+
+	// repeated items get their own buckets
+	lb.Update(NewChoicesSlice([]string{"X", "X", "Y"}))
+	count = make(map[interface{}]int)
+	for i := 0; i < 3000; i++ {
+		count[lb.Select(strconv.Itoa(i))]++
+	}
+	if count["X"] < 1800 || count["Y"] < 800 {
+		t.Fatalf("anchor repeated items wrong: %v", count)
+	}
+}
+
+// owners returns the item of each key.
+func owners(lb Balancer, keys int) []interface{} {
+	items := make([]interface{}, keys)
+	for i := range items {
+		items[i] = lb.Select("key-" + strconv.Itoa(i))
+	}
+	return items
+}
+
+func TestAnchorHash_Churn(t *testing.T) {
+	const keys = 20000
+	r := rand.New(rand.NewSource(1))
+	nodes := genNodes(100)
+	current := append([]*Choice{}, nodes...)
+	lb := NewAnchorHashWithCapacity(128, current...)
+	before := owners(lb, keys)
+	initial := before
+
+	var (
+		removed    []*Choice
+		totalMoved int
+		totalIdeal float64
+	)
+	for step := 0; step < 60; step++ {
+		if len(removed) > 0 && r.Intn(3) == 0 {
+			// add back the last removed item
+			c := removed[len(removed)-1]
+			removed = removed[:len(removed)-1]
+			current = append(current, c)
+			lb.Update(current)
+			after := owners(lb, keys)
+			moved := 0
+			for i := range after {
+				if after[i] != before[i] {
+					if after[i] != c.Item {
+						t.Fatalf("anchor moved a key of %v to %v", before[i], after[i])
+					}
+					moved++
+				}
+			}
+			totalMoved += moved
+			totalIdeal += float64(keys) / float64(len(current))
+			before = after
+			continue
+		}
+
+		// remove any item
+		i := r.Intn(len(current))
+		c := current[i]
+		current = append(current[:i:i], current[i+1:]...)
+		removed = append(removed, c)
+		lb.Update(current)
+		after := owners(lb, keys)
+		for i := range after {
+			if after[i] != before[i] {
+				if before[i] != c.Item {
+					t.Fatalf("anchor moved a key of %v to %v", before[i], after[i])
+				}
+				totalMoved++
+			}
+		}
+		totalIdeal += float64(keys) / float64(len(current)+1)
+		before = after
+	}
+
+	// only the keys of the changed items move, about 1/n of the keys per change
+	rate := float64(totalMoved) / totalIdeal
+	t.Logf("anchor remapped %d keys under churn, %.2f of the ideal", totalMoved, rate)
+	if rate > 1.2 {
+		t.Fatalf("anchor remap rate %.2f of the ideal", rate)
+	}
+
+	// the keys go back to their items once all of them are back
+	for len(removed) > 0 {
+		current = append(current, removed[len(removed)-1])
+		removed = removed[:len(removed)-1]
+	}
+	lb.Update(current)
+	after := owners(lb, keys)
+	for i := range after {
+		if after[i] != initial[i] {
+			t.Fatal("anchor expected the initial owners")
+		}
+	}
+}
+
+func TestAnchorHash_Balance(t *testing.T) {
+	const keys = 100000
+	r := rand.New(rand.NewSource(2))
+	nodes := genNodes(200)
+	lb := NewAnchorHash(nodes...)
+
+	// remove half of the items at random
+	r.Shuffle(len(nodes), func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] })
+	for len(nodes) > 100 {
+		nodes = nodes[:len(nodes)-1]
+		lb.Update(nodes)
+	}
+	anchor := peakToMean(lb, keys, len(nodes))
+	if anchor > 1.3 {
+		t.Fatalf("anchor peak-to-mean after removals %.2f", anchor)
+	}
+	t.Logf("peak-to-mean after removing 100 of 200 items: anchor %.2f, ideal 1", anchor)
+}
+
+func TestAnchorHash_Grow(t *testing.T) {
+	nodes := genNodes(10)
+	lb := NewAnchorHashWithCapacity(4, nodes...)
+	if lb.capacity != 16 {
+		t.Fatalf("anchor expected capacity 16, actual %d", lb.capacity)
+	}
+	count := make(map[interface{}]int)
+	for i := 0; i < 1000; i++ {
+		count[lb.Select(strconv.Itoa(i))]++
+	}
+	if len(count) != 10 {
+		t.Fatalf("anchor grow wrong: %v", count)
+	}
+}
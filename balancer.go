@@ -1,7 +1,9 @@
 package balancer
 
 import (
+	"fmt"
 	"reflect"
+	"sync/atomic"
 
 	"github.com/shibingli/load-balancer/utils"
 )
@@ -15,22 +17,166 @@ type Balancer interface {
 	Name() string
 
 	// Update reinitialize the balancer items.
+	// It reports whether at least one item can be selected.
 	Update(choices []*Choice) bool
 }
 
+// ChoiceSelector is implemented by the built-in balancers, to tell why no item can be selected.
+//
+//	if s, ok := lb.(balancer.ChoiceSelector); ok {
+//		item, err = s.SelectE(key)
+//	}
+type ChoiceSelector interface {
+	// SelectE is like Select, but reports why no item can be selected,
+	// so that a nil item is not mistaken for a missing one.
+	SelectE(key ...string) (interface{}, error)
+
+	// SelectChoice is like SelectE, but returns the selected *Choice.
+	// Choices that are not Available are skipped.
+	SelectChoice(key ...string) (*Choice, error)
+}
+
+// MultiSelector is implemented by the built-in balancers, to select several distinct items at once.
+type MultiSelector interface {
+	// SelectN gets up to n distinct items, fewer if not enough are available:
+	// the next n of the rotation for round-robin balancers, a weighted sample without replacement
+	// for random balancers and the owner followed by n-1 stable fallbacks for the hash balancers.
+	SelectN(n int, key ...string) []interface{}
+}
+
+// ErrorUpdater is implemented by the built-in balancers, to tell why no item can be selected after an Update.
+type ErrorUpdater interface {
+	// UpdateE is like Update, but reports why no item can be selected.
+	UpdateE(choices []*Choice) error
+}
+
+// selectChoice selects with lb, a ChoiceSelector or not, and returns the selected *Choice.
+// The choice of a balancer that is not a ChoiceSelector only holds the item.
+func selectChoice(lb Balancer, key []string) (*Choice, error) {
+	if s, ok := lb.(ChoiceSelector); ok {
+		return s.SelectChoice(key...)
+	}
+	item := lb.Select(key...)
+	if item == nil {
+		return nil, ErrNoChoices
+	}
+	return &Choice{Item: item}, nil
+}
+
+// selectN selects up to n distinct items with lb, at most one if it is not a MultiSelector.
+func selectN(lb Balancer, n int, key []string) []interface{} {
+	if s, ok := lb.(MultiSelector); ok {
+		return s.SelectN(n, key...)
+	}
+	if n <= 0 {
+		return nil
+	}
+	if item := lb.Select(key...); item != nil {
+		return []interface{}{item}
+	}
+	return nil
+}
+
+// updateE updates lb, an ErrorUpdater or not.
+func updateE(lb Balancer, choices []*Choice) error {
+	if u, ok := lb.(ErrorUpdater); ok {
+		return u.UpdateE(choices)
+	}
+	if !lb.Update(choices) {
+		return ErrNoChoices
+	}
+	return nil
+}
+
 // Choice to be selected for the load balancer
 type Choice struct {
 	// e.g. server addr / node / *url.URL
 	Item interface{}
 
+	// ID identifies the choice, optional, see Key
+	ID string
+
 	// For WeightedRoundRobin / SmoothWeightedRoundRobin / WeightedRand
 	Weight int
 
 	// For SmoothWeightedRoundRobin, optional
 	CurrentWeight int
+
+	// Labels e.g. zone / rack / version, optional
+	Labels map[string]string
+
+	// Maximum number of in-flight requests through Cluster.SelectContext, optional, 0 is unlimited
+	MaxConcurrency int
+
+	ejected  int32
+	inflight int32
+	limit    int32
+}
+
+// Key returns the identity of the choice: ID if set, else the item formatted with fmt.Sprint,
+// which is the String of a fmt.Stringer. The hash balancers place the choices by it,
+// and choices of the same key are the same across updates, e.g. for Cluster and the metrics.
+func (c *Choice) Key() string {
+	if c.ID != "" {
+		return c.ID
+	}
+	if s, ok := c.Item.(string); ok {
+		return s
+	}
+	return fmt.Sprint(c.Item)
+}
+
+// Ejected reports whether the choice has been ejected by Cluster.Eject.
+func (c *Choice) Ejected() bool {
+	return atomic.LoadInt32(&c.ejected) != 0
 }
 
-// Mode defines the selectable balancer algorithm.
+// Inflight returns the number of requests in flight through Cluster.SelectContext.
+func (c *Choice) Inflight() int {
+	return int(atomic.LoadInt32(&c.inflight))
+}
+
+// Limit returns the concurrency limit: the lower of MaxConcurrency and the adaptive limit
+// set by Cluster.SetLimiter, 0 is unlimited.
+func (c *Choice) Limit() int {
+	max := c.MaxConcurrency
+	if l := int(atomic.LoadInt32(&c.limit)); l > 0 && (max <= 0 || l < max) {
+		max = l
+	}
+	return max
+}
+
+// Available reports whether the choice is neither ejected nor at its concurrency limit.
+func (c *Choice) Available() bool {
+	if atomic.LoadInt32(&c.ejected) != 0 {
+		return false
+	}
+	max := c.Limit()
+	return max <= 0 || atomic.LoadInt32(&c.inflight) < int32(max)
+}
+
+// acquire takes one slot of the concurrency limit and returns the number of requests in flight.
+func (c *Choice) acquire() (int, bool) {
+	for {
+		n := atomic.LoadInt32(&c.inflight)
+		if atomic.LoadInt32(&c.ejected) != 0 {
+			return 0, false
+		}
+		if max := c.Limit(); max > 0 && n >= int32(max) {
+			return 0, false
+		}
+		if atomic.CompareAndSwapInt32(&c.inflight, n, n+1) {
+			return int(n + 1), true
+		}
+	}
+}
+
+// release gives back a slot taken by acquire.
+func (c *Choice) release() {
+	atomic.AddInt32(&c.inflight, -1)
+}
+
+// Mode defines the selectable balancer algorithm, more can be added with Register.
 type Mode int
 
 const (
@@ -41,6 +187,10 @@ const (
 	ConsistentHash
 	RoundRobin
 	Random
+	WeightedRandAlias
+	MultiProbeConsistentHash
+	AnchorHash
+	RendezvousHash
 )
 
 // NewChoice create new items with optional weights.
@@ -94,28 +244,27 @@ func NewChoicesSlice(items interface{}) (choices []*Choice) {
 	return
 }
 
-// New create a balancer with or without items.
-func New(b Mode, choices []*Choice) Balancer {
-	switch b {
-	case SmoothWeightedRoundRobin:
-		return NewSmoothWeightedRoundRobin(choices...)
-	case RoundRobin:
-		return NewRoundRobin(choices...)
-	case WeightedRand:
-		return NewWeightedRand(choices...)
-	case ConsistentHash:
-		return NewConsistentHash(choices...)
-	case Random:
-		return NewRandom(choices...)
-	default:
-		return NewWeightedRoundRobin(choices...)
+// Discard nil items
+func cleanChoices(choices []*Choice) (items []*Choice, n int) {
+	items = make([]*Choice, 0, len(choices))
+	for i := range choices {
+		if choices[i] == nil {
+			continue
+		}
+		items = append(items, choices[i])
+		n++
 	}
+	return
 }
 
-// Discard items with a weight less than 1
-func cleanWeight(choices []*Choice) (items []*Choice, n int) {
+// Discard nil items and items with a weight less than 1, total is the number of non-nil items
+func cleanWeight(choices []*Choice) (items []*Choice, n int, total int) {
 	items = make([]*Choice, 0, len(choices))
 	for i := range choices {
+		if choices[i] == nil {
+			continue
+		}
+		total++
 		if choices[i].Weight <= 0 {
 			continue
 		}
@@ -124,3 +273,12 @@ func cleanWeight(choices []*Choice) (items []*Choice, n int) {
 	}
 	return
 }
+
+func anyAvailable(items []*Choice) bool {
+	for i := range items {
+		if items[i].Available() {
+			return true
+		}
+	}
+	return false
+}
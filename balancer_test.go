@@ -1,6 +1,8 @@
 package balancer
 
 import (
+	"net/url"
+	"strconv"
 	"testing"
 )
 
@@ -62,3 +64,73 @@ func TestBalancer(t *testing.T) {
 		t.Fatal("balancer select wrong")
 	}
 }
+
+func TestChoice_Key(t *testing.T) {
+	u, _ := url.Parse("http://10.0.0.1:8080/api")
+	for _, v := range []struct {
+		choice *Choice
+		key    string
+	}{
+		{&Choice{Item: "A"}, "A"},
+		{&Choice{Item: "A", ID: "node-1"}, "node-1"},
+		{&Choice{Item: u}, "http://10.0.0.1:8080/api"},
+		{&Choice{Item: 42}, "42"},
+		{&Choice{Item: []string{"a", "b"}}, "[a b]"},
+		{&Choice{Item: map[string]int{"a": 1}, ID: "m"}, "m"},
+		{&Choice{}, "<nil>"},
+	} {
+		if key := v.choice.Key(); key != v.key {
+			t.Fatalf("key expected %s, actual %s", v.key, key)
+		}
+	}
+}
+
+// TestChoice_NotComparable uses items that cannot be map keys, told apart by their Key.
+func TestChoice_NotComparable(t *testing.T) {
+	for _, m := range []Mode{WeightedRoundRobin, SmoothWeightedRoundRobin, WeightedRand, ConsistentHash, RoundRobin, Random, WeightedRandAlias, MultiProbeConsistentHash, AnchorHash, RendezvousHash} {
+		choices := func() []*Choice {
+			return []*Choice{
+				{Item: []string{"10.0.0.1", "10.0.0.2"}, ID: "shard-a", Weight: 1},
+				{Item: []string{"10.0.0.3"}, ID: "shard-b", Weight: 1},
+				{Item: map[string]int{"10.0.0.4": 1}, ID: "shard-c", Weight: 1},
+			}
+		}
+		lb := newBuiltin(m, choices())
+		for i := 0; i < 10; i++ {
+			if _, err := lb.SelectChoice(strconv.Itoa(i)); err != nil {
+				t.Fatalf("%s select wrong: %v", m, err)
+			}
+			if items := lb.SelectN(3, strconv.Itoa(i)); len(items) != 3 {
+				t.Fatalf("%s expected 3 items, actual %v", m, items)
+			}
+		}
+
+		a, ok := lb.(Analyzer)
+		if !ok {
+			continue
+		}
+		// the same keys: nothing moves
+		if r := a.Remap(choices()); r.Moved != 0 {
+			t.Fatalf("%s remap expected no keys to move, actual %f", m, r.Moved)
+		}
+		mig := NewMigration(lb, newBuiltin(m, choices()))
+		for i := 0; i < 100; i++ {
+			owner, previous, err := mig.OwnerChoices(strconv.Itoa(i))
+			if err != nil || owner.Key() != previous.Key() {
+				t.Fatal("migration expected no keys to move")
+			}
+		}
+	}
+}
+
+// builtin is implemented by all the built-in balancers.
+type builtin interface {
+	Balancer
+	ChoiceSelector
+	MultiSelector
+	ErrorUpdater
+}
+
+func newBuiltin(m Mode, choices []*Choice) builtin {
+	return New(m, choices).(builtin)
+}
@@ -40,6 +40,15 @@ func BenchmarkBalancer(b *testing.B) {
 			}
 		})
 
+		b.Run("WRAlias-"+strconv.Itoa(n), func(b *testing.B) {
+			choices := genChoices(n)
+			lb := NewWeightedRandAlias(choices...)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lb.Select()
+			}
+		})
+
 		b.Run("Hash-"+strconv.Itoa(n), func(b *testing.B) {
 			choices := genChoices(n)
 			lb := NewConsistentHash(choices...)
@@ -49,6 +58,33 @@ func BenchmarkBalancer(b *testing.B) {
 			}
 		})
 
+		b.Run("MultiProbeHash-"+strconv.Itoa(n), func(b *testing.B) {
+			choices := genChoices(n)
+			lb := NewMultiProbeConsistentHash(choices...)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lb.Select("192.168.1.1")
+			}
+		})
+
+		b.Run("AnchorHash-"+strconv.Itoa(n), func(b *testing.B) {
+			choices := genChoices(n)
+			lb := NewAnchorHashWithCapacity(n, choices...)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lb.Select("192.168.1.1")
+			}
+		})
+
+		b.Run("RendezvousHash-"+strconv.Itoa(n), func(b *testing.B) {
+			choices := genChoices(n)
+			lb := NewRendezvousHash(choices...)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lb.Select("192.168.1.1")
+			}
+		})
+
 		b.Run("RoundRobin-"+strconv.Itoa(n), func(b *testing.B) {
 			choices := genChoices(n)
 			lb := NewRoundRobin(choices...)
@@ -104,6 +140,17 @@ func BenchmarkBalancerParallel(b *testing.B) {
 			})
 		})
 
+		b.Run("WRAlias-"+strconv.Itoa(n), func(b *testing.B) {
+			choices := genChoices(n)
+			lb := NewWeightedRandAlias(choices...)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					lb.Select()
+				}
+			})
+		})
+
 		b.Run("Hash-"+strconv.Itoa(n), func(b *testing.B) {
 			choices := genChoices(n)
 			lb := NewConsistentHash(choices...)
@@ -139,6 +186,72 @@ func BenchmarkBalancerParallel(b *testing.B) {
 	}
 }
 
+func BenchmarkSchedule(b *testing.B) {
+	for n := numMin; n <= numMax; n *= 10 {
+		b.Run("WRR-"+strconv.Itoa(n), func(b *testing.B) {
+			choices := genChoices(n)
+			lb := NewWeightedRoundRobinWithSchedule(n*20, choices...)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lb.Select()
+			}
+		})
+
+		b.Run("SWRR-"+strconv.Itoa(n), func(b *testing.B) {
+			choices := genChoices(n)
+			lb := NewSmoothWeightedRoundRobinWithSchedule(n*20, choices...)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lb.Select()
+			}
+		})
+	}
+}
+
+func BenchmarkHashKey(b *testing.B) {
+	lb := NewConsistentHash(genChoices(1000)...)
+	key := []byte("192.168.1.100:443")
+	b.Run("Select", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			lb.Select("192.168.1.100", ":443")
+		}
+	})
+
+	b.Run("SelectBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			lb.SelectBytes(key)
+		}
+	})
+
+	b.Run("SelectHash", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			lb.SelectHash(uint64(i))
+		}
+	})
+}
+
+func BenchmarkWeightedRandUpdate(b *testing.B) {
+	for n := numMin; n <= numMax; n *= 10 {
+		choices := genChoices(n)
+		b.Run("WR-"+strconv.Itoa(n), func(b *testing.B) {
+			lb := NewWeightedRand()
+			for i := 0; i < b.N; i++ {
+				lb.Update(choices)
+			}
+		})
+
+		b.Run("WRAlias-"+strconv.Itoa(n), func(b *testing.B) {
+			lb := NewWeightedRandAlias()
+			for i := 0; i < b.N; i++ {
+				lb.Update(choices)
+			}
+		})
+	}
+}
+
 func genChoices(n int) []*Choice {
 	choices := make([]*Choice, n)
 	for i := 0; i < n; i++ {
@@ -0,0 +1,439 @@
+package balancer
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cluster wraps a Balancer with ejection, per-choice concurrency limits and waiting for capacity.
+// Unlike the plain balancers, it is goroutine-safe.
+type Cluster struct {
+	mu      sync.Mutex
+	lb      Balancer
+	choices []*Choice
+
+	newLimiter func() Limiter
+	states     map[*Choice]*choiceState
+
+	selections uint64
+	errors     uint64
+	latency    histogram
+
+	observers observers
+
+	// FIFO queue of SelectContext callers waiting for capacity, of chan struct{}
+	waiters list.List
+}
+
+// NewCluster create a cluster with the balancer and its choices.
+func NewCluster(lb Balancer, choices ...*Choice) *Cluster {
+	c := &Cluster{lb: lb}
+	c.Update(choices)
+	return c
+}
+
+// Lease is a selected choice holding one slot of its concurrency limit.
+type Lease struct {
+	// Item of the selected choice
+	Item interface{}
+
+	// Choice selected
+	Choice *Choice
+
+	c        *Cluster
+	state    *choiceState
+	limiter  *limiter
+	start    time.Time
+	inflight int
+	done     int32
+}
+
+// Done gives the slot back, err is the result of the request, if any.
+// It is safe to call Done more than once.
+func (l *Lease) Done(err error) {
+	if !atomic.CompareAndSwapInt32(&l.done, 0, 1) {
+		return
+	}
+	rtt := time.Since(l.start)
+
+	l.c.mu.Lock()
+	// the choice of the same Key after an Update took over the slot
+	choice := l.Choice
+	if l.state != nil && l.state.current != nil {
+		choice = l.state.current
+	}
+	if l.limiter != nil {
+		l.limiter.sample(choice, rtt, l.inflight, err != nil)
+	}
+	choice.release()
+	l.c.wakeLocked()
+	l.c.mu.Unlock()
+}
+
+// choiceState is the state of one choice in a Cluster.
+type choiceState struct {
+	selections uint64
+	limiter    *limiter
+	// current is the choice of the state, a new one of the same Key after an Update, guarded by Cluster.mu
+	current *Choice
+}
+
+// limiter serializes the samples of one choice.
+type limiter struct {
+	mu sync.Mutex
+	l  Limiter
+}
+
+func (l *limiter) sample(choice *Choice, rtt time.Duration, inflight int, dropped bool) {
+	l.mu.Lock()
+	l.l.Sample(rtt, inflight, dropped)
+	atomic.StoreInt32(&choice.limit, int32(l.l.Limit()))
+	l.mu.Unlock()
+}
+
+// SelectOption configures SelectContext.
+type SelectOption func(*selectOptions)
+
+type selectOptions struct {
+	key []string
+}
+
+// WithKey sets the key for hash balancers.
+func WithKey(key ...string) SelectOption {
+	return func(o *selectOptions) {
+		o.key = key
+	}
+}
+
+// SelectContext selects a choice and takes one slot of its concurrency limit.
+// When every choice is ejected or saturated, it waits in FIFO order until one becomes available,
+// or returns the context error. The returned Lease must be released with Done.
+func (c *Cluster) SelectContext(ctx context.Context, opts ...SelectOption) (*Lease, error) {
+	var o selectOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c.mu.Lock()
+	// callers already waiting go first
+	if c.waiters.Len() == 0 {
+		l, err := c.acquire(o.key)
+		if err == nil || !canWait(err) {
+			c.mu.Unlock()
+			c.notifySelect(l, o.key)
+			return l, err
+		}
+	}
+	ch := make(chan struct{}, 1)
+	e := c.waiters.PushBack(ch)
+	c.mu.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.mu.Lock()
+			c.waiters.Remove(e)
+			select {
+			case <-ch:
+				// pass on the wakeup we are not going to use
+				c.wakeLocked()
+			default:
+			}
+			c.mu.Unlock()
+			return nil, ctx.Err()
+		case <-ch:
+			c.mu.Lock()
+			l, err := c.acquire(o.key)
+			if err == nil || !canWait(err) {
+				c.waiters.Remove(e)
+				if err == nil {
+					// there may be capacity left for the next one
+					c.wakeLocked()
+				}
+				c.mu.Unlock()
+				c.notifySelect(l, o.key)
+				return l, err
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+func canWait(err error) bool {
+	return errors.Is(err, ErrNoChoices) || errors.Is(err, ErrAllUnhealthy) || errors.Is(err, ErrSaturated)
+}
+
+func (c *Cluster) acquire(key []string) (*Lease, error) {
+	_, skips := c.lb.(ChoiceSelector)
+	for tries := 0; ; tries++ {
+		choice, err := c.selectChoice(key)
+		if err != nil {
+			return nil, err
+		}
+		// the choice may have been taken between Available and acquire
+		n, ok := choice.acquire()
+		if !ok {
+			// a balancer that is not a ChoiceSelector selects the unavailable choice again
+			if !skips || tries >= len(c.choices) {
+				return nil, unavailable([]*Choice{choice})
+			}
+			continue
+		}
+		l := &Lease{
+			Item:     choice.Item,
+			Choice:   choice,
+			c:        c,
+			start:    time.Now(),
+			inflight: n,
+		}
+		if s := c.states[choice]; s != nil {
+			l.state, l.limiter = s, s.limiter
+		}
+		return l, nil
+	}
+}
+
+// selectChoice selects and records the selection, c.mu must be held.
+func (c *Cluster) selectChoice(key []string) (*Choice, error) {
+	start := time.Now()
+	choice, err := selectChoice(c.lb, key)
+	c.latency.observe(time.Since(start))
+	if err != nil {
+		atomic.AddUint64(&c.errors, 1)
+		return nil, err
+	}
+	atomic.AddUint64(&c.selections, 1)
+	s := c.states[choice]
+	if s == nil {
+		choice = c.choiceOf(choice)
+		s = c.states[choice]
+	}
+	if s != nil {
+		atomic.AddUint64(&s.selections, 1)
+	}
+	return choice, nil
+}
+
+// choiceOf returns the choice of the item selected by a balancer that is not a ChoiceSelector,
+// or selected itself if the item is not one of the choices, c.mu must be held.
+func (c *Cluster) choiceOf(selected *Choice) *Choice {
+	key := selected.Key()
+	for _, choice := range c.choices {
+		if choice != nil && (&Choice{Item: choice.Item}).Key() == key {
+			return choice
+		}
+	}
+	return selected
+}
+
+func (c *Cluster) wake() {
+	c.mu.Lock()
+	c.wakeLocked()
+	c.mu.Unlock()
+}
+
+// wakeLocked wakes the first waiter, c.mu must be held.
+func (c *Cluster) wakeLocked() {
+	if e := c.waiters.Front(); e != nil {
+		select {
+		case e.Value.(chan struct{}) <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *Cluster) notifySelect(l *Lease, key []string) {
+	if l != nil {
+		c.observers.onSelect(l.Item, key)
+	}
+}
+
+// Eject stops the choice from being selected until Recover, reason is passed on to observers.
+func (c *Cluster) Eject(choice *Choice, reason string) {
+	if atomic.CompareAndSwapInt32(&choice.ejected, 0, 1) {
+		c.observers.onEject(choice.Item, reason)
+	}
+}
+
+// Recover makes an ejected choice selectable again.
+func (c *Cluster) Recover(choice *Choice) {
+	if atomic.CompareAndSwapInt32(&choice.ejected, 1, 0) {
+		c.wake()
+		c.observers.onRecover(choice.Item)
+	}
+}
+
+// Observe attaches an observer to the cluster, the returned function detaches it.
+func (c *Cluster) Observe(o Observer) (remove func()) {
+	return c.observers.add(o)
+}
+
+// SetLimiter enables an adaptive concurrency limit per choice, e.g.
+//
+//	c.SetLimiter(func() balancer.Limiter { return balancer.NewAIMD(10) })
+//
+// The adaptive limit applies on top of Choice.MaxConcurrency, nil disables it.
+func (c *Cluster) SetLimiter(newLimiter func() Limiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.newLimiter = newLimiter
+	for choice, s := range c.states {
+		s.limiter = nil
+		atomic.StoreInt32(&choice.limit, 0)
+	}
+	c.updateStates()
+	c.wakeLocked()
+}
+
+// updateStates keeps the states of the current choices, c.mu must be held.
+// A new choice of the Key of a previous one takes over its state, its ejection and its slots in use.
+func (c *Cluster) updateStates() {
+	prev := make(map[string]*Choice, len(c.states))
+	for choice := range c.states {
+		prev[choice.Key()] = choice
+	}
+	for _, choice := range c.choices {
+		if _, ok := c.states[choice]; ok && choice != nil {
+			delete(prev, choice.Key())
+		}
+	}
+
+	states := make(map[*Choice]*choiceState, len(c.choices))
+	for _, choice := range c.choices {
+		if choice == nil {
+			continue
+		}
+		s, ok := c.states[choice]
+		if p := prev[choice.Key()]; !ok && p != nil {
+			s, ok = c.states[p], true
+			delete(prev, choice.Key())
+			atomic.StoreInt32(&choice.ejected, atomic.LoadInt32(&p.ejected))
+			atomic.StoreInt32(&choice.limit, atomic.LoadInt32(&p.limit))
+			atomic.StoreInt32(&choice.inflight, atomic.LoadInt32(&p.inflight))
+		}
+		if !ok {
+			s = &choiceState{}
+		}
+		if s.limiter == nil && c.newLimiter != nil {
+			s.limiter = &limiter{l: c.newLimiter()}
+			atomic.StoreInt32(&choice.limit, int32(s.limiter.l.Limit()))
+		}
+		s.current = choice
+		states[choice] = s
+	}
+	c.states = states
+}
+
+// Choices returns the current choices.
+func (c *Cluster) Choices() []*Choice {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.choices
+}
+
+// Select gets next selected item, without taking a slot of its concurrency limit.
+func (c *Cluster) Select(key ...string) (item interface{}) {
+	item, _ = c.SelectE(key...)
+	return
+}
+
+func (c *Cluster) SelectE(key ...string) (interface{}, error) {
+	choice, err := c.SelectChoice(key...)
+	if err != nil {
+		return nil, err
+	}
+	return choice.Item, nil
+}
+
+func (c *Cluster) SelectChoice(key ...string) (*Choice, error) {
+	c.mu.Lock()
+	choice, err := c.selectChoice(key)
+	c.mu.Unlock()
+	if err == nil {
+		c.observers.onSelect(choice.Item, key)
+	}
+	return choice, err
+}
+
+// SelectN selects up to n distinct items, without taking slots of their concurrency limits.
+func (c *Cluster) SelectN(n int, key ...string) []interface{} {
+	return c.selectMany(n, key, func() ([]*Choice, bool) {
+		if s, ok := c.lb.(nSelector); ok {
+			return s.selectN(n, key), true
+		}
+		return nil, false
+	}, func() []interface{} {
+		return selectN(c.lb, n, key)
+	})
+}
+
+// SelectSpread is like SelectN, with the items in distinct values of the label, see SpreadSelector.
+// It returns nil if the balancer is not a SpreadSelector.
+func (c *Cluster) SelectSpread(n int, label string, key ...string) []interface{} {
+	return c.selectMany(n, key, func() ([]*Choice, bool) {
+		if s, ok := c.lb.(spreadSelector); ok {
+			return s.selectSpread(n, label, key), true
+		}
+		return nil, false
+	}, func() []interface{} {
+		if s, ok := c.lb.(SpreadSelector); ok {
+			return s.SelectSpread(n, label, key...)
+		}
+		return nil
+	})
+}
+
+// selectMany records the selections of choose, or of selectItems if the balancer is not a built-in one.
+func (c *Cluster) selectMany(n int, key []string, choose func() ([]*Choice, bool), selectItems func() []interface{}) []interface{} {
+	c.mu.Lock()
+	start := time.Now()
+	choices, ok := choose()
+	var items []interface{}
+	if ok {
+		for _, choice := range choices {
+			if s := c.states[choice]; s != nil {
+				atomic.AddUint64(&s.selections, 1)
+			}
+		}
+		items = choiceItems(choices)
+	} else {
+		items = selectItems()
+	}
+	c.latency.observe(time.Since(start))
+	if len(items) == 0 && n > 0 {
+		atomic.AddUint64(&c.errors, 1)
+	}
+	atomic.AddUint64(&c.selections, uint64(len(items)))
+	c.mu.Unlock()
+
+	for _, item := range items {
+		c.observers.onSelect(item, key)
+	}
+	return items
+}
+
+func (c *Cluster) Name() string {
+	return c.lb.Name()
+}
+
+func (c *Cluster) Update(choices []*Choice) bool {
+	return c.UpdateE(choices) == nil
+}
+
+func (c *Cluster) UpdateE(choices []*Choice) error {
+	c.mu.Lock()
+	old := c.choices
+	err := updateE(c.lb, choices)
+	c.choices = choices
+	c.updateStates()
+	c.wakeLocked()
+	c.mu.Unlock()
+
+	c.observers.onUpdate(old, choices)
+	return err
+}
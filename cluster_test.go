@@ -0,0 +1,326 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCluster(t *testing.T) {
+	a := &Choice{Item: "A", Weight: 1, MaxConcurrency: 1}
+	b := &Choice{Item: "B", Weight: 1, MaxConcurrency: 1}
+	c := NewCluster(NewRoundRobin(), a, b)
+	if c.Name() != "RoundRobin" || len(c.Choices()) != 2 {
+		t.Fatal("cluster wrong")
+	}
+
+	ctx := context.Background()
+	l1, err := c.SelectContext(ctx)
+	if err != nil || l1.Item != "A" || a.Inflight() != 1 {
+		t.Fatal("cluster SelectContext wrong")
+	}
+	l2, err := c.SelectContext(ctx)
+	if err != nil || l2.Item != "B" {
+		t.Fatal("cluster SelectContext wrong")
+	}
+	if _, err := c.SelectE(); !errors.Is(err, ErrSaturated) {
+		t.Fatalf("cluster expected ErrSaturated, actual %v", err)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.SelectContext(tctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("cluster expected DeadlineExceeded, actual %v", err)
+	}
+
+	l2.Done(nil)
+	l2.Done(nil)
+	if b.Inflight() != 0 {
+		t.Fatal("cluster Done wrong")
+	}
+	l3, err := c.SelectContext(ctx)
+	if err != nil || l3.Item != "B" {
+		t.Fatal("cluster SelectContext wrong")
+	}
+	l1.Done(nil)
+	l3.Done(nil)
+
+	c.Eject(a, "test")
+	c.Eject(b, "test")
+	if !a.Ejected() || a.Available() {
+		t.Fatal("cluster Eject wrong")
+	}
+	if _, err := c.SelectE(); !errors.Is(err, ErrAllUnhealthy) {
+		t.Fatalf("cluster expected ErrAllUnhealthy, actual %v", err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c.Recover(b)
+	}()
+	l, err := c.SelectContext(ctx)
+	if err != nil || l.Item != "B" {
+		t.Fatal("cluster Recover wrong")
+	}
+	l.Done(nil)
+
+	h := NewCluster(NewConsistentHash(), a, b)
+	if _, err := h.SelectContext(ctx); !errors.Is(err, ErrKeyRequired) {
+		t.Fatalf("cluster expected ErrKeyRequired, actual %v", err)
+	}
+	if l, err := h.SelectContext(ctx, WithKey("192.168.1.1")); err != nil || l.Item != "B" {
+		t.Fatal("cluster WithKey wrong")
+	}
+}
+
+func TestCluster_FIFO(t *testing.T) {
+	a := &Choice{Item: "A", Weight: 1, MaxConcurrency: 1}
+	c := NewCluster(NewWeightedRoundRobin(), a)
+	ctx := context.Background()
+	first, err := c.SelectContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		i := i
+		go func() {
+			l, err := c.SelectContext(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- i
+			l.Done(nil)
+		}()
+		// wait until the caller is queued
+		for {
+			c.mu.Lock()
+			n := c.waiters.Len()
+			c.mu.Unlock()
+			if n == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	first.Done(nil)
+	for i := 0; i < 3; i++ {
+		if v := <-order; v != i {
+			t.Fatalf("cluster expected waiter %d, actual %d", i, v)
+		}
+	}
+}
+
+func TestCluster_C(t *testing.T) {
+	choices := []*Choice{
+		{Item: "A", Weight: 3, MaxConcurrency: 2},
+		{Item: "B", Weight: 1, MaxConcurrency: 2},
+		{Item: "C", Weight: 1, MaxConcurrency: 1},
+	}
+	c := NewCluster(NewSmoothWeightedRoundRobin(), choices...)
+
+	var (
+		wg   sync.WaitGroup
+		over int64
+		n    int64
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				l, err := c.SelectContext(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if l.Choice.Inflight() > l.Choice.MaxConcurrency {
+					atomic.AddInt64(&over, 1)
+				}
+				atomic.AddInt64(&n, 1)
+				l.Done(nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if atomic.LoadInt64(&over) != 0 || atomic.LoadInt64(&n) != 10000 {
+		t.Fatalf("cluster concurrency wrong: over %d, n %d", over, n)
+	}
+	for _, v := range choices {
+		if v.Inflight() != 0 {
+			t.Fatalf("cluster inflight wrong: %s", v.Item)
+		}
+	}
+}
+
+func TestSelectChoice_Skip(t *testing.T) {
+	for _, m := range []Mode{WeightedRoundRobin, SmoothWeightedRoundRobin, WeightedRand, ConsistentHash, RoundRobin, Random, WeightedRandAlias, MultiProbeConsistentHash, AnchorHash, RendezvousHash} {
+		choices := []*Choice{
+			{Item: "A", Weight: 5},
+			{Item: "B", Weight: 1},
+			{Item: "C", Weight: 2},
+		}
+		c := NewCluster(New(m, nil), choices...)
+		c.Eject(choices[0], "test")
+		count := make(map[interface{}]int)
+		for i := 0; i < 300; i++ {
+			item, err := c.SelectE(strconv.Itoa(i))
+			if err != nil {
+				t.Fatalf("%s: %v", m, err)
+			}
+			count[item]++
+		}
+		if count["A"] != 0 || count["B"] == 0 || count["C"] == 0 {
+			t.Fatalf("%s skip wrong: %v", m, count)
+		}
+
+		c.Eject(choices[1], "test")
+		c.Eject(choices[2], "test")
+		if _, err := c.SelectE("k"); !errors.Is(err, ErrAllUnhealthy) {
+			t.Fatalf("%s expected ErrAllUnhealthy, actual %v", m, err)
+		}
+	}
+}
+
+func TestCluster_UpdateKey(t *testing.T) {
+	c := NewCluster(NewRoundRobin(), NewChoicesSlice([]string{"A", "B"})...)
+	for i := 0; i < 4; i++ {
+		c.Select()
+	}
+	c.Eject(c.Choices()[1], "down")
+
+	// a new but equal list keeps the selections and the ejection
+	c.Update(NewChoicesSlice([]string{"A", "B", "C"}))
+	s := c.Stats()
+	if s.Choices[0].Selections != 2 || s.Choices[1].Selections != 2 || s.Choices[2].Selections != 0 {
+		t.Fatalf("cluster expected the selections to be kept: %+v", s.Choices)
+	}
+	if !s.Choices[1].Ejected || s.Choices[0].Ejected || s.Choices[1].Key != "B" {
+		t.Fatal("cluster expected the ejection to be kept")
+	}
+
+	// told apart by ID
+	c.Update([]*Choice{{Item: "A", ID: "a-1", Weight: 1}, {Item: "A", ID: "a-2", Weight: 1}})
+	s = c.Stats()
+	if s.Choices[0].Selections != 0 || s.Choices[0].Key != "a-1" || s.Choices[1].Key != "a-2" {
+		t.Fatalf("cluster expected new choices: %+v", s.Choices)
+	}
+}
+
+func TestCluster_UpdateLeases(t *testing.T) {
+	c := NewCluster(NewRoundRobin(), &Choice{Item: "A", Weight: 1, MaxConcurrency: 1})
+	c.SetLimiter(func() Limiter { return NewAIMD(10) })
+	l, err := c.SelectContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the slot in use is taken over by the new choice
+	next := &Choice{Item: "A", Weight: 1, MaxConcurrency: 1}
+	c.Update([]*Choice{next})
+	if _, err := c.SelectE(); !errors.Is(err, ErrSaturated) {
+		t.Fatalf("cluster expected ErrSaturated, actual %v", err)
+	}
+
+	l.Done(errors.New("dropped"))
+	if atomic.LoadInt32(&next.inflight) != 0 || atomic.LoadInt32(&l.Choice.inflight) != 1 {
+		t.Fatal("cluster expected the slot to be given back to the new choice")
+	}
+	if limit := atomic.LoadInt32(&next.limit); limit != 9 {
+		t.Fatalf("cluster expected the sample to limit the new choice, actual %d", limit)
+	}
+	if _, err := c.SelectE(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// minimal only implements Balancer, like a third-party plugin.
+type minimal struct {
+	items []*Choice
+}
+
+func (b *minimal) Select(_ ...string) interface{} {
+	if len(b.items) == 0 {
+		return nil
+	}
+	return b.items[len(b.items)-1].Item
+}
+
+func (b *minimal) Name() string {
+	return "Minimal"
+}
+
+func (b *minimal) Update(choices []*Choice) bool {
+	b.items = choices
+	return len(choices) > 0
+}
+
+func TestCluster_Minimal(t *testing.T) {
+	choices := NewChoicesSlice([]string{"A", "B"})
+	choices[1].MaxConcurrency = 2
+	c := NewCluster(&minimal{}, choices...)
+
+	l, err := c.SelectContext(context.Background())
+	if err != nil || l.Choice != choices[1] || choices[1].Inflight() != 1 {
+		t.Fatalf("cluster expected the choice of the item, actual %v %v", l, err)
+	}
+	l.Done(nil)
+	if c.Stats().Choices[1].Selections != 1 {
+		t.Fatal("cluster expected the selection to be recorded")
+	}
+	if items := c.SelectN(2); len(items) != 1 || items[0] != "B" {
+		t.Fatalf("cluster expected one item, actual %v", items)
+	}
+	if _, err := c.SelectE(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UpdateE(nil); !errors.Is(err, ErrNoChoices) {
+		t.Fatal("cluster expected ErrNoChoices")
+	}
+	if _, err := c.SelectE(); !errors.Is(err, ErrNoChoices) {
+		t.Fatal("cluster expected ErrNoChoices")
+	}
+
+	m := NewMigration(&minimal{items: choices[:1]}, &minimal{items: choices})
+	if owner, previous, err := m.Owners(); err != nil || owner != "B" || previous != "A" {
+		t.Fatalf("migration owners wrong: %v %v %v", owner, previous, err)
+	}
+}
+
+func TestCluster_MinimalSaturated(t *testing.T) {
+	choices := NewChoicesSlice([]string{"A"})
+	choices[0].MaxConcurrency = 1
+	c := NewCluster(&minimal{}, choices...)
+
+	l, err := c.SelectContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the balancer keeps selecting the saturated choice: the second one waits for the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := c.SelectContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("cluster expected the deadline, actual %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		l, err := c.SelectContext(context.Background())
+		if err == nil {
+			l.Done(nil)
+		}
+		done <- err
+	}()
+	l.Done(nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
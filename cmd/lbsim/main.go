@@ -0,0 +1,215 @@
+// Command lbsim simulates the distribution of a balancer.
+//
+// It loads a choice list, runs N selections and prints the share of each item, the deviation
+// from the ideal weight ratio, the longest consecutive run and a chi-square statistic.
+// With -add / -remove it also reports the share of keys that move to another item.
+//
+//	lbsim -mode swrr -n 100000 backends.json
+//	lbsim -mode hash -keys zipf -add E=1 -remove B backends.txt
+//
+// The choice list is either the JSON file of balancer.Watcher or "item=weight" lines.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	balancer "github.com/shibingli/load-balancer"
+)
+
+type options struct {
+	mode     string
+	n        int
+	keys     string
+	keyspace int
+	zipfS    float64
+	seed     int64
+	add      string
+	remove   string
+	file     string
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "lbsim:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	var o options
+	fs := flag.NewFlagSet("lbsim", flag.ContinueOnError)
+	fs.StringVar(&o.mode, "mode", "wrr", "balancer mode, one of: "+strings.Join(balancer.Modes(), ", "))
+	fs.IntVar(&o.n, "n", 100000, "number of selections")
+	fs.StringVar(&o.keys, "keys", "uniform", "key distribution for hash modes: uniform or zipf")
+	fs.IntVar(&o.keyspace, "keyspace", 10000, "number of distinct keys")
+	fs.Float64Var(&o.zipfS, "zipf-s", 1.1, "zipf exponent, > 1")
+	fs.Int64Var(&o.seed, "seed", 1, "seed of the key generator")
+	fs.StringVar(&o.add, "add", "", "items to add for the remap report, e.g. E=1,F=2")
+	fs.StringVar(&o.remove, "remove", "", "items to remove for the remap report, e.g. A,B")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: lbsim [flags] [file], reads stdin without a file")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		o.file = fs.Arg(0)
+	}
+
+	mode, err := balancer.ParseMode(o.mode)
+	if err != nil {
+		return err
+	}
+	if o.n <= 0 || o.keyspace <= 0 {
+		return errors.New("-n and -keyspace must be positive")
+	}
+
+	var data []byte
+	if o.file == "" || o.file == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(o.file)
+	}
+	if err != nil {
+		return err
+	}
+	choices, err := parseChoices(data)
+	if err != nil {
+		return err
+	}
+
+	keys, err := newKeys(o)
+	if err != nil {
+		return err
+	}
+
+	lb, ok := balancer.New(mode, choices).(balancer.ChoiceSelector)
+	if !ok {
+		return fmt.Errorf("mode %s does not report its selections", mode)
+	}
+	r := simulate(lb, choices, weighted(mode), o.n, keys)
+	r.print(stdout, mode, o)
+
+	if o.add != "" || o.remove != "" {
+		after, err := changeChoices(choices, o.add, o.remove)
+		if err != nil {
+			return err
+		}
+		printRemap(stdout, mode, choices, after, o)
+	}
+	return nil
+}
+
+// weighted reports whether the mode follows the weights, other modes share equally.
+func weighted(m balancer.Mode) bool {
+	switch m {
+	case balancer.WeightedRoundRobin, balancer.SmoothWeightedRoundRobin, balancer.WeightedRand, balancer.WeightedRandAlias:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseChoices parses the JSON file of balancer.Watcher, or "item=weight" lines.
+func parseChoices(data []byte) ([]*balancer.Choice, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		return balancer.ParseChoices(data)
+	}
+
+	var choices []*balancer.Choice
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		c, err := parseChoice(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		choices = append(choices, c)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(choices) == 0 {
+		return nil, errors.New("no choices")
+	}
+	return choices, nil
+}
+
+// parseChoice parses "item=weight" or "item", the weight defaults to 1.
+func parseChoice(s string) (*balancer.Choice, error) {
+	item, weight := s, 1
+	if i := strings.LastIndexByte(s, '='); i >= 0 {
+		w, err := strconv.Atoi(strings.TrimSpace(s[i+1:]))
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight: %q", s)
+		}
+		item, weight = strings.TrimSpace(s[:i]), w
+	}
+	if item == "" {
+		return nil, fmt.Errorf("empty item: %q", s)
+	}
+	return &balancer.Choice{Item: item, Weight: weight}, nil
+}
+
+// changeChoices returns a copy of the choices with items added and removed.
+func changeChoices(choices []*balancer.Choice, add, remove string) ([]*balancer.Choice, error) {
+	removed := make(map[string]bool)
+	for _, s := range strings.Split(remove, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			removed[s] = true
+		}
+	}
+
+	var after []*balancer.Choice
+	for _, c := range choices {
+		if !removed[c.Key()] {
+			after = append(after, &balancer.Choice{Item: c.Item, ID: c.ID, Weight: c.Weight})
+		}
+	}
+	for _, s := range strings.Split(add, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		c, err := parseChoice(s)
+		if err != nil {
+			return nil, err
+		}
+		after = append(after, c)
+	}
+	return after, nil
+}
+
+// newKeys returns the key generator, keys are drawn from a keyspace of o.keyspace keys.
+func newKeys(o options) (func() string, error) {
+	r := rand.New(rand.NewSource(o.seed))
+	switch o.keys {
+	case "uniform":
+		return func() string {
+			return "key-" + strconv.Itoa(r.Intn(o.keyspace))
+		}, nil
+	case "zipf":
+		if o.zipfS <= 1 {
+			return nil, errors.New("-zipf-s must be greater than 1")
+		}
+		z := rand.NewZipf(r, o.zipfS, 1, uint64(o.keyspace-1))
+		return func() string {
+			return "key-" + strconv.FormatUint(z.Uint64(), 10)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown key distribution: %q", o.keys)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	balancer "github.com/shibingli/load-balancer"
+)
+
+func TestParseChoices(t *testing.T) {
+	choices, err := parseChoices([]byte("# backends\nA=5\n\nB = 3\nC\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(choices) != 3 || choices[0].Weight != 5 || choices[1].Item != "B" || choices[2].Weight != 1 {
+		t.Fatal("parse choices wrong")
+	}
+
+	choices, err = parseChoices([]byte(`{"items": [{"item": "A", "weight": 2}]}`))
+	if err != nil || len(choices) != 1 || choices[0].Weight != 2 {
+		t.Fatal("parse json wrong")
+	}
+
+	for _, s := range []string{"", "A=x", "A=-1", "=1"} {
+		if _, err := parseChoices([]byte(s)); err == nil {
+			t.Fatalf("parse expected error: %q", s)
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	choices := []*balancer.Choice{{Item: "A", Weight: 5}, {Item: "B", Weight: 1}, {Item: "C", Weight: 1}}
+	keys := func() string { return "" }
+
+	r := simulate(balancer.NewSmoothWeightedRoundRobin(choices...), choices, true, 7000, keys)
+	if r.items[0].count != 5000 || r.items[1].count != 1000 {
+		t.Fatal("simulate count wrong")
+	}
+	if chi, df := r.chiSquare(); chi != 0 || df != 2 {
+		t.Fatalf("simulate chi-square wrong: %f %d", chi, df)
+	}
+	smooth := r.items[0].longest
+
+	// the classic wrr is burstier
+	r = simulate(balancer.NewWeightedRoundRobin(choices...), choices, true, 7000, keys)
+	if r.items[0].longest <= smooth {
+		t.Fatalf("simulate longest run wrong: wrr %d, swrr %d", r.items[0].longest, smooth)
+	}
+}
+
+func TestRemap(t *testing.T) {
+	before := []*balancer.Choice{{Item: "A"}, {Item: "B"}, {Item: "C"}}
+	after, err := changeChoices(before, "D=1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idealRemap(before, after) != 0.25 {
+		t.Fatal("ideal remap wrong")
+	}
+
+	moved, ok := remap(balancer.NewConsistentHash(before...), balancer.NewConsistentHash(after...), 1000)
+	if !ok || moved <= 0 || moved >= 0.5 {
+		t.Fatalf("remap wrong: %f", moved)
+	}
+	if _, ok := remap(balancer.NewRoundRobin(before...), balancer.NewRoundRobin(after...), 1000); ok {
+		t.Fatal("remap expected round robin not to map keys")
+	}
+}
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	err := run([]string{"-mode", "hash", "-n", "1000", "-keys", "zipf", "-remove", "B"}, strings.NewReader("A\nB\nC\n"), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"mode: hash", "chi-square:", "remap: 3 -> 2 items"} {
+		if !strings.Contains(out.String(), s) {
+			t.Fatalf("run output missing %q:\n%s", s, out.String())
+		}
+	}
+
+	if err := run([]string{"-mode", "nope"}, strings.NewReader("A\n"), &out); err == nil {
+		t.Fatal("run expected error")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"text/tabwriter"
+
+	balancer "github.com/shibingli/load-balancer"
+)
+
+type itemResult struct {
+	item    string
+	weight  int
+	count   int
+	ideal   float64
+	longest int
+}
+
+type result struct {
+	n      int
+	errors int
+	items  []itemResult
+}
+
+// simulate runs n selections, keys are passed to every selection.
+func simulate(lb balancer.ChoiceSelector, choices []*balancer.Choice, weighted bool, n int, keys func() string) result {
+	r := result{n: n, items: make([]itemResult, len(choices))}
+	index := make(map[string]int, len(choices))
+
+	total := 0
+	for i, c := range choices {
+		w := 1
+		if weighted {
+			w = c.Weight
+			if w < 0 {
+				w = 0
+			}
+		}
+		total += w
+		r.items[i] = itemResult{item: c.Key(), weight: c.Weight, ideal: float64(w)}
+		index[r.items[i].item] = i
+	}
+	for i := range r.items {
+		if total > 0 {
+			r.items[i].ideal /= float64(total)
+		}
+	}
+
+	last, run := -1, 0
+	for j := 0; j < n; j++ {
+		c, err := lb.SelectChoice(keys())
+		if err != nil {
+			r.errors++
+			last, run = -1, 0
+			continue
+		}
+		i := index[c.Key()]
+		r.items[i].count++
+		if i == last {
+			run++
+		} else {
+			last, run = i, 1
+		}
+		if run > r.items[i].longest {
+			r.items[i].longest = run
+		}
+	}
+	return r
+}
+
+// chiSquare returns the chi-square statistic of the counts against the ideal shares,
+// and its degrees of freedom.
+func (r result) chiSquare() (float64, int) {
+	chi, k := 0.0, 0
+	n := float64(r.n - r.errors)
+	for _, v := range r.items {
+		expected := v.ideal * n
+		if expected == 0 {
+			continue
+		}
+		d := float64(v.count) - expected
+		chi += d * d / expected
+		k++
+	}
+	if k == 0 {
+		return 0, 0
+	}
+	return chi, k - 1
+}
+
+func (r result) print(w io.Writer, mode balancer.Mode, o options) {
+	fmt.Fprintf(w, "mode: %s, selections: %d, keys: %s/%d\n\n", mode, r.n, o.keys, o.keyspace)
+
+	n := float64(r.n - r.errors)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "item\tweight\tcount\tshare\tideal\tdeviation\tlongest run\t")
+	for _, v := range r.items {
+		share := 0.0
+		if n > 0 {
+			share = float64(v.count) / n
+		}
+		deviation := "-"
+		if v.ideal > 0 {
+			deviation = fmt.Sprintf("%+.2f%%", (share-v.ideal)/v.ideal*100)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f%%\t%.2f%%\t%s\t%d\t\n",
+			v.item, v.weight, v.count, share*100, v.ideal*100, deviation, v.longest)
+	}
+	_ = tw.Flush()
+
+	chi, df := r.chiSquare()
+	fmt.Fprintf(w, "\nchi-square: %.2f (df %d)\n", chi, df)
+	if r.errors > 0 {
+		fmt.Fprintf(w, "errors: %d\n", r.errors)
+	}
+}
+
+// remap returns the share of keys of the keyspace whose owner differs between the balancers,
+// ok is false if the mode does not map keys to items.
+func remap(before, after balancer.Balancer, keyspace int) (moved float64, ok bool) {
+	owners := make([]interface{}, keyspace)
+	for i := range owners {
+		owners[i] = before.Select("key-" + strconv.Itoa(i))
+	}
+	for i := range owners {
+		if before.Select("key-"+strconv.Itoa(i)) != owners[i] {
+			return 0, false
+		}
+	}
+
+	n := 0
+	for i := range owners {
+		if after.Select("key-"+strconv.Itoa(i)) != owners[i] {
+			n++
+		}
+	}
+	return float64(n) / float64(keyspace), true
+}
+
+// idealRemap is the least share of keys that has to move between equally loaded item sets.
+func idealRemap(before, after []*balancer.Choice) float64 {
+	seen := make(map[string]bool, len(before))
+	for _, c := range before {
+		seen[c.Key()] = true
+	}
+	common := 0
+	for _, c := range after {
+		if seen[c.Key()] {
+			common++
+		}
+	}
+	max := math.Max(float64(len(before)), float64(len(after)))
+	if max == 0 {
+		return 0
+	}
+	return 1 - float64(common)/max
+}
+
+func printRemap(w io.Writer, mode balancer.Mode, before, after []*balancer.Choice, o options) {
+	fmt.Fprintf(w, "\nremap: %d -> %d items", len(before), len(after))
+	moved, ok := remap(balancer.New(mode, before), balancer.New(mode, after), o.keyspace)
+	if !ok {
+		fmt.Fprintf(w, ", %s does not map keys to items\n", mode)
+		return
+	}
+	fmt.Fprintf(w, ", %.2f%% of %d keys moved (ideal %.2f%%)\n", moved*100, o.keyspace, idealRemap(before, after)*100)
+}
@@ -0,0 +1,209 @@
+package balancer
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ChoiceLister is implemented by balancers that can list their choices,
+// all built-in balancers and Cluster implement it.
+type ChoiceLister interface {
+	Choices() []*Choice
+}
+
+// State is a snapshot of a published balancer.
+type State struct {
+	// Name the balancer is published under
+	Name string `json:"name"`
+
+	// Balancer is the Name() of the balancer.
+	Balancer string `json:"balancer"`
+
+	// Mode is the registered name of the mode, empty if unknown.
+	Mode string `json:"mode,omitempty"`
+
+	// Selections and Errors are only known for a Cluster.
+	Selections uint64 `json:"selections,omitempty"`
+	Errors     uint64 `json:"errors,omitempty"`
+
+	Choices []ChoiceState `json:"choices"`
+}
+
+// ChoiceState is a snapshot of one choice of a published balancer.
+type ChoiceState struct {
+	Item          string            `json:"item"`
+	Key           string            `json:"key"`
+	Weight        int               `json:"weight"`
+	CurrentWeight int               `json:"current_weight"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Selections    uint64            `json:"selections,omitempty"`
+	Inflight      int               `json:"inflight"`
+	Limit         int               `json:"limit,omitempty"`
+	Ejected       bool              `json:"ejected"`
+	Available     bool              `json:"available"`
+}
+
+var published = struct {
+	sync.RWMutex
+	m map[string]*Cluster
+}{
+	m: make(map[string]*Cluster),
+}
+
+// Publish publishes the state of the cluster as the expvar "balancer.<name>", visible in /debug/vars,
+// and lists it in DebugHandler. Publishing a name again replaces its cluster, e.g.
+//
+//	c := balancer.NewCluster(balancer.NewSmoothWeightedRoundRobin(), choices...)
+//	balancer.Publish("api", c)
+//
+// The state is read while the expvar is rendered, concurrently with the selections:
+// a plain balancer has to be wrapped in a Cluster, which takes its lock.
+func Publish(name string, c *Cluster) {
+	published.Lock()
+	defer published.Unlock()
+
+	if _, ok := published.m[name]; !ok && expvar.Get("balancer."+name) == nil {
+		expvar.Publish("balancer."+name, expvar.Func(func() interface{} {
+			state, ok := PublishedState(name)
+			if !ok {
+				return nil
+			}
+			return state
+		}))
+	}
+	published.m[name] = c
+}
+
+// Unpublish removes the balancer from DebugHandler, its expvar renders null.
+func Unpublish(name string) {
+	published.Lock()
+	delete(published.m, name)
+	published.Unlock()
+}
+
+// PublishedState returns the state of the balancer published under the name.
+func PublishedState(name string) (State, bool) {
+	published.RLock()
+	c, ok := published.m[name]
+	published.RUnlock()
+	if !ok {
+		return State{}, false
+	}
+	return StateOf(name, c), true
+}
+
+// PublishedStates returns the states of all published balancers sorted by name.
+func PublishedStates() []State {
+	published.RLock()
+	names := make([]string, 0, len(published.m))
+	for name := range published.m {
+		names = append(names, name)
+	}
+	published.RUnlock()
+	sort.Strings(names)
+
+	states := make([]State, 0, len(names))
+	for _, name := range names {
+		if state, ok := PublishedState(name); ok {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+// StateOf returns a snapshot of the balancer, its choices are listed if it implements ChoiceLister.
+// A plain balancer must not be selected from or updated concurrently, unlike a Cluster.
+func StateOf(name string, lb Balancer) State {
+	state := State{
+		Name:     name,
+		Balancer: lb.Name(),
+		Choices:  []ChoiceState{},
+	}
+	if m, err := ParseMode(state.Balancer); err == nil {
+		state.Mode = m.String()
+	}
+
+	if c, ok := lb.(*Cluster); ok {
+		s := c.Stats()
+		state.Selections, state.Errors = s.Selections, s.Errors
+		for _, cs := range s.Choices {
+			state.Choices = append(state.Choices, ChoiceState{
+				Item:          fmt.Sprint(cs.Item),
+				Key:           cs.Key,
+				Weight:        cs.Weight,
+				CurrentWeight: cs.CurrentWeight,
+				Labels:        cs.Labels,
+				Selections:    cs.Selections,
+				Inflight:      cs.Inflight,
+				Limit:         cs.Limit,
+				Ejected:       cs.Ejected,
+				Available:     cs.Available,
+			})
+		}
+		return state
+	}
+
+	if l, ok := lb.(ChoiceLister); ok {
+		for _, choice := range l.Choices() {
+			if choice == nil {
+				continue
+			}
+			state.Choices = append(state.Choices, ChoiceState{
+				Item:          fmt.Sprint(choice.Item),
+				Key:           choice.Key(),
+				Weight:        choice.Weight,
+				CurrentWeight: choice.CurrentWeight,
+				Labels:        choice.Labels,
+				Inflight:      choice.Inflight(),
+				Limit:         choice.Limit(),
+				Ejected:       choice.Ejected(),
+				Available:     choice.Available(),
+			})
+		}
+	}
+	return state
+}
+
+// DebugHandler returns an http.Handler listing all published balancers,
+// as JSON, or as HTML for browsers and with ?format=html.
+func DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		states := PublishedStates()
+		format := r.URL.Query().Get("format")
+		if format == "html" || (format == "" && strings.Contains(r.Header.Get("Accept"), "text/html")) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_ = debugTemplate.Execute(w, states)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(states)
+	})
+}
+
+var debugTemplate = template.Must(template.New("balancers").Parse(`<!DOCTYPE html>
+<html>
+<head><title>balancers</title></head>
+<body>
+{{range .}}
+<h2>{{.Name}} <small>{{.Balancer}}{{if .Mode}} ({{.Mode}}){{end}}</small></h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>item</th><th>weight</th><th>current weight</th><th>selections</th><th>inflight</th><th>limit</th><th>ejected</th><th>available</th><th>labels</th></tr>
+{{range .Choices}}<tr>
+<td>{{.Item}}</td><td>{{.Weight}}</td><td>{{.CurrentWeight}}</td><td>{{.Selections}}</td><td>{{.Inflight}}</td><td>{{.Limit}}</td><td>{{.Ejected}}</td><td>{{.Available}}</td><td>{{range $k, $v := .Labels}}{{$k}}={{$v}} {{end}}</td>
+</tr>
+{{end}}</table>
+{{else}}
+<p>no balancers published</p>
+{{end}}
+</body>
+</html>
+`))
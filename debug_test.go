@@ -0,0 +1,95 @@
+package balancer
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPublish(t *testing.T) {
+	lb := NewCluster(NewSmoothWeightedRoundRobin(),
+		&Choice{Item: "A", Weight: 2, Labels: map[string]string{"zone": "a"}},
+		&Choice{Item: "B", Weight: 1},
+		&Choice{Item: "C", Weight: 0},
+	)
+	lb.Select()
+	Publish("test-swrr", lb)
+
+	c := NewCluster(NewRoundRobin(), NewChoicesSlice([]string{"X", "Y"})...)
+	c.Select()
+	c.Eject(c.Choices()[1], "test")
+	Publish("test-cluster", c)
+	defer Unpublish("test-swrr")
+	defer Unpublish("test-cluster")
+
+	v := expvar.Get("balancer.test-swrr")
+	if v == nil {
+		t.Fatal("expvar not published")
+	}
+	var state State
+	if err := json.Unmarshal([]byte(v.String()), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Name != "test-swrr" || state.Balancer != "SmoothWeightedRoundRobin" || state.Mode != "swrr" {
+		t.Fatalf("state wrong: %+v", state)
+	}
+	if len(state.Choices) != 3 || state.Choices[0].Item != "A" || state.Choices[0].Labels["zone"] != "a" ||
+		state.Choices[2].Weight != 0 || state.Choices[0].CurrentWeight != -1 {
+		t.Fatalf("state choices wrong: %+v", state.Choices)
+	}
+
+	state, ok := PublishedState("test-cluster")
+	if !ok || state.Mode != "rr" || state.Selections != 1 || len(state.Choices) != 2 {
+		t.Fatalf("cluster state wrong: %+v", state)
+	}
+	if state.Choices[0].Selections != 1 || !state.Choices[1].Ejected || state.Choices[1].Available {
+		t.Fatalf("cluster state wrong: %+v", state.Choices)
+	}
+
+	// the state is read while the cluster selects
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			lb.Select()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = PublishedStates()
+	}
+	<-done
+
+	// publishing again replaces the balancer
+	Publish("test-swrr", NewCluster(NewRandom()))
+	if state, _ := PublishedState("test-swrr"); state.Balancer != "Random" || len(state.Choices) != 0 {
+		t.Fatal("publish again wrong")
+	}
+
+	rr := httptest.NewRecorder()
+	DebugHandler().ServeHTTP(rr, httptest.NewRequest("GET", "/debug/balancers", nil))
+	var states []State
+	if err := json.Unmarshal(rr.Body.Bytes(), &states); err != nil {
+		t.Fatal(err)
+	}
+	names := ""
+	for _, s := range states {
+		names += s.Name + ","
+	}
+	if !strings.Contains(names, "test-cluster,test-swrr,") {
+		t.Fatalf("debug handler wrong: %s", names)
+	}
+
+	rr = httptest.NewRecorder()
+	DebugHandler().ServeHTTP(rr, httptest.NewRequest("GET", "/debug/balancers?format=html", nil))
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") ||
+		!strings.Contains(rr.Body.String(), "<td>X</td>") {
+		t.Fatalf("debug handler html wrong: %s", rr.Body.String())
+	}
+
+	Unpublish("test-swrr")
+	if _, ok := PublishedState("test-swrr"); ok || expvar.Get("balancer.test-swrr").String() != "null" {
+		t.Fatal("unpublish wrong")
+	}
+}
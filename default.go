@@ -8,6 +8,16 @@ func Select(_ ...string) interface{} {
 	return DefaultBalancer.Select()
 }
 
+// SelectE gets next selected item, or an error saying why there is none.
+func SelectE(key ...string) (interface{}, error) {
+	return DefaultBalancer.SelectE(key...)
+}
+
+// SelectN gets up to n distinct items.
+func SelectN(n int, key ...string) []interface{} {
+	return DefaultBalancer.SelectN(n, key...)
+}
+
 // Name load balancer name.
 func Name() string {
 	return DefaultBalancer.Name()
@@ -17,3 +27,8 @@ func Name() string {
 func Update(choices []*Choice) bool {
 	return DefaultBalancer.Update(choices)
 }
+
+// UpdateE reinitialize the balancer items, or an error saying why none can be selected.
+func UpdateE(choices []*Choice) error {
+	return DefaultBalancer.UpdateE(choices)
+}
@@ -38,6 +38,9 @@ func TestDefaultBalancer(t *testing.T) {
 	if item != "Y" {
 		t.Fatal("default balancer update wrong")
 	}
+	if items := SelectN(2); len(items) != 1 || items[0] != "Y" {
+		t.Fatal("default balancer SelectN wrong")
+	}
 
 	if Name() != "WeightedRoundRobin" {
 		t.Fatal("default balancer name wrong")
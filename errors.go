@@ -0,0 +1,42 @@
+package balancer
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNoChoices is returned when the balancer has no items at all.
+	ErrNoChoices = errors.New("balancer: no choices")
+
+	// ErrAllUnhealthy is returned when the balancer has items, but none of them can be selected,
+	// e.g. all weights are less than 1 or all items are ejected.
+	ErrAllUnhealthy = errors.New("balancer: no healthy choices")
+
+	// ErrSaturated is returned when every healthy item is at its concurrency limit.
+	ErrSaturated = errors.New("balancer: all choices saturated")
+
+	// ErrKeyRequired is returned by hash balancers when no key is given.
+	ErrKeyRequired = errors.New("balancer: key required")
+
+	// ErrUnknownMode is returned for a mode name that has not been registered.
+	ErrUnknownMode = errors.New("balancer: unknown mode")
+)
+
+// noChoice returns the error of a balancer without selectable items,
+// total is the number of items it has been given.
+func noChoice(total int) error {
+	if total > 0 {
+		return ErrAllUnhealthy
+	}
+	return ErrNoChoices
+}
+
+// unavailable returns the error of a balancer whose items are all ejected or saturated.
+func unavailable(items []*Choice) error {
+	for i := range items {
+		if !items[i].Ejected() {
+			return ErrSaturated
+		}
+	}
+	return ErrAllUnhealthy
+}
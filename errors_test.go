@@ -0,0 +1,80 @@
+package balancer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelectE(t *testing.T) {
+	for _, m := range []Mode{WeightedRoundRobin, SmoothWeightedRoundRobin, WeightedRand, ConsistentHash, RoundRobin, Random} {
+		lb := newBuiltin(m, nil)
+		if _, err := lb.SelectE("k"); !errors.Is(err, ErrNoChoices) {
+			t.Fatalf("%s expected ErrNoChoices, actual %v", m, err)
+		}
+		if err := lb.UpdateE(nil); !errors.Is(err, ErrNoChoices) {
+			t.Fatalf("%s expected ErrNoChoices, actual %v", m, err)
+		}
+		if lb.Update([]*Choice{nil}) {
+			t.Fatalf("%s update expected false", m)
+		}
+
+		// a nil item is a legitimate item
+		if err := lb.UpdateE([]*Choice{nil, {Item: nil, Weight: 1}}); err != nil {
+			t.Fatalf("%s update wrong: %v", m, err)
+		}
+		item, err := lb.SelectE("k")
+		if item != nil || err != nil {
+			t.Fatalf("%s expected nil item, actual %v, %v", m, item, err)
+		}
+	}
+
+	for _, m := range []Mode{WeightedRoundRobin, SmoothWeightedRoundRobin, WeightedRand} {
+		lb := newBuiltin(m, nil)
+		err := lb.UpdateE([]*Choice{{Item: "A"}, {Item: "B", Weight: -1}})
+		if !errors.Is(err, ErrAllUnhealthy) {
+			t.Fatalf("%s expected ErrAllUnhealthy, actual %v", m, err)
+		}
+		if _, err := lb.SelectE(); !errors.Is(err, ErrAllUnhealthy) {
+			t.Fatalf("%s expected ErrAllUnhealthy, actual %v", m, err)
+		}
+		if lb.Select() != nil {
+			t.Fatalf("%s expected nil", m)
+		}
+	}
+
+	lb := NewConsistentHash(NewChoicesSlice([]string{"A", "B"})...)
+	if _, err := lb.SelectE(); !errors.Is(err, ErrKeyRequired) {
+		t.Fatalf("hash expected ErrKeyRequired, actual %v", err)
+	}
+	if item, err := lb.SelectE("192.168.1.100"); err != nil || item != lb.Select("192.168.1.100") {
+		t.Fatal("hash SelectE wrong")
+	}
+
+	if err := UpdateE([]*Choice{{Item: "X", Weight: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if item, err := SelectE(); err != nil || item != "X" {
+		t.Fatal("default balancer SelectE wrong")
+	}
+}
+
+func TestWeightedRoundRobin_ZeroWeights(t *testing.T) {
+	// zero weights in front of the items used to break the gcd / max settings
+	lb := NewWeightedRoundRobin(
+		&Choice{Item: "A", Weight: 0},
+		&Choice{Item: "B", Weight: 0},
+		&Choice{Item: "C", Weight: 1},
+		&Choice{Item: "D", Weight: 2},
+	)
+	count := make(map[interface{}]int)
+	for i := 0; i < 300; i++ {
+		item, err := lb.SelectE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		count[item]++
+	}
+	if count["C"] != 100 || count["D"] != 200 {
+		t.Fatalf("wrr wrong: %v", count)
+	}
+}
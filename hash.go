@@ -1,28 +1,122 @@
 package balancer
 
 import (
+	"sort"
+
+	"github.com/shibingli/load-balancer/hasher"
 	"github.com/shibingli/load-balancer/internal/doublejump"
 	"github.com/shibingli/load-balancer/utils"
 )
 
-// JumpConsistentHash
+// HashSelector is implemented by the hash balancers, to select without allocating for the key.
+//
+//	if hs, ok := lb.(balancer.HashSelector); ok {
+//		item = hs.SelectBytes(packet.FlowKey())
+//	}
+type HashSelector interface {
+	// SelectHash gets the item of a key already hashed with the hasher of the balancer.
+	SelectHash(hash uint64) interface{}
+
+	// SelectBytes gets the item of the key, the same as Select(string(key)).
+	SelectBytes(key []byte) interface{}
+}
+
+// JumpConsistentHash over the items sorted by identity, so that the placement only depends
+// on the set of the items, not on their order.
 type consistentHash struct {
-	count int
-	h     *doublejump.Hash
+	// items sorted by identity, the buckets of the jump hash
+	sorted []*Choice
+	h      *doublejump.Hash
+	hashBalancer
 }
 
 func NewConsistentHash(choices ...*Choice) (lb *consistentHash) {
-	lb = &consistentHash{}
+	return NewConsistentHashWithHasher(nil, choices...)
+}
+
+// NewConsistentHashWithHasher create a ConsistentHash balancer hashing the keys with h,
+// e.g. hasher.XXHash64{}. A nil h uses FNV-1a.
+func NewConsistentHashWithHasher(h hasher.Hasher, choices ...*Choice) (lb *consistentHash) {
+	lb = &consistentHash{hashBalancer: hashBalancer{keyHasher: keyHasher{h}}}
+	lb.placement = lb
 	lb.Update(choices)
 	return
 }
 
-func (b *consistentHash) Select(key ...string) (item interface{}) {
+// SetHasher replaces the hasher of the keys, e.g. to rotate the secret of a hasher.SipHash,
+// see MovedKeys. Like Update, it must not be called concurrently with a selection.
+func (b *consistentHash) SetHasher(h hasher.Hasher) {
+	b.hasher = h
+}
+
+// MovedKeys reports which of the keys would be owned by another item with the hasher h,
+// e.g. the cached or sticky keys to migrate before a key rotation:
+//
+//	next, _ := hasher.NewRandomSipHash()
+//	moved := lb.MovedKeys(next, sessions...)
+//	lb.SetHasher(next)
+func (b *consistentHash) MovedKeys(h hasher.Hasher, keys ...string) (moved []string) {
 	if b.count == 0 {
-		return
+		return nil
+	}
+	next := keyHasher{h}
+	for _, key := range keys {
+		if b.owner(b.hashKey([]string{key})) != b.owner(next.hashKey([]string{key})) {
+			moved = append(moved, key)
+		}
+	}
+	return moved
+}
+
+func (b *consistentHash) chooseN(n int, hash uint64) []*Choice {
+	c, err := b.choose(hash)
+	if err != nil {
+		return nil
+	}
+	choices := make([]*Choice, 1, n)
+	choices[0] = c
+	seen := map[*Choice]struct{}{c: {}}
+
+	// the probes of choose, then the items in order
+	for i := 0; i < 4*b.count && len(choices) < n; i++ {
+		hash = hash*0x9e3779b97f4a7c15 + 1
+		c = b.h.Get(hash).(*Choice)
+		if _, ok := seen[c]; !ok && c.Available() {
+			seen[c] = struct{}{}
+			choices = append(choices, c)
+		}
+	}
+	for _, c = range b.sorted {
+		if len(choices) == n {
+			break
+		}
+		if _, ok := seen[c]; !ok && c.Available() {
+			seen[c] = struct{}{}
+			choices = append(choices, c)
+		}
+	}
+	return choices
+}
+
+// choose returns the owner of the hash, or a consistent fallback if the owner is not available.
+func (b *consistentHash) choose(hash uint64) (*Choice, error) {
+	c := b.h.Get(hash).(*Choice)
+	if c.Available() {
+		return c, nil
+	}
+
+	for i := 1; i < b.count; i++ {
+		hash = hash*0x9e3779b97f4a7c15 + 1
+		if c = b.h.Get(hash).(*Choice); c.Available() {
+			return c, nil
+		}
+	}
+	for _, c = range b.sorted {
+		if c.Available() {
+			return c, nil
+		}
 	}
-	hash := utils.HashString(key...)
-	return b.h.Get(hash).(*Choice).Item
+	return nil, unavailable(b.items)
 }
 
 func (b *consistentHash) Name() string {
@@ -30,10 +124,203 @@ func (b *consistentHash) Name() string {
 }
 
 func (b *consistentHash) Update(choices []*Choice) bool {
-	b.count = len(choices)
+	return b.UpdateE(choices) == nil
+}
+
+func (b *consistentHash) UpdateE(choices []*Choice) error {
+	b.items, b.count = cleanChoices(choices)
+	b.labels = &labelCounts{}
+	b.sorted = sortByID(b.items)
 	b.h = doublejump.NewHash()
-	for i := range choices {
-		b.h.Add(choices[i])
+	for i := range b.sorted {
+		b.h.Add(b.sorted[i])
+	}
+	if b.count == 0 {
+		return ErrNoChoices
+	}
+	return nil
+}
+
+// Ownership returns the exact ownership of jump hash: the keyspace is spread evenly.
+func (b *consistentHash) Ownership() Ownership {
+	return evenOwnership(b.items)
+}
+
+// Remap estimates the keys moving by an Update with the choices, see Analyzer.
+func (b *consistentHash) Remap(choices []*Choice) Remap {
+	next := NewConsistentHashWithHasher(b.hasher, choices...)
+	return sampleRemap(b.owner, next.owner)
+}
+
+func (b *consistentHash) owner(hash uint64) *Choice {
+	if b.count == 0 {
+		return nil
+	}
+	return b.h.Get(hash).(*Choice)
+}
+
+// sortByID returns a copy of the items sorted by the bytes of their Key.
+func sortByID(items []*Choice) []*Choice {
+	ids := make([]string, len(items))
+	sorted := make([]int, len(items))
+	for i := range items {
+		ids[i] = items[i].Key()
+		sorted[i] = i
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return ids[sorted[i]] < ids[sorted[j]]
+	})
+
+	choices := make([]*Choice, len(items))
+	for i, j := range sorted {
+		choices[i] = items[j]
+	}
+	return choices
+}
+
+// hashPlacement is the placement of the keys of a hash balancer.
+type hashPlacement interface {
+	// choose returns the owner of the hash, or a consistent fallback if the owner is not available.
+	choose(hash uint64) (*Choice, error)
+
+	// chooseN returns the choice of choose followed by up to n-1 distinct available fallbacks,
+	// 0 < n <= count. The same hash gets the same choices as long as the choices do not change.
+	chooseN(n int, hash uint64) []*Choice
+
+	// owner returns the owner of the hash regardless of its availability, nil without items.
+	owner(hash uint64) *Choice
+}
+
+// hashBalancer implements the selections of the hash balancers over their placement.
+type hashBalancer struct {
+	items []*Choice
+	count int
+	// items of each label value, for SelectSpread
+	labels *labelCounts
+	keyHasher
+	// placement is the hash balancer itself
+	placement hashPlacement
+}
+
+func (b *hashBalancer) Select(key ...string) (item interface{}) {
+	return b.SelectHash(b.hashKey(key))
+}
+
+// SelectHash is like Select, with a key hashed by the caller with the hasher of the balancer.
+func (b *hashBalancer) SelectHash(hash uint64) (item interface{}) {
+	if b.count == 0 {
+		return
+	}
+	c, err := b.placement.choose(hash)
+	if err != nil {
+		return
+	}
+	return c.Item
+}
+
+// SelectBytes is like Select with the key string(key), without converting it.
+func (b *hashBalancer) SelectBytes(key []byte) interface{} {
+	return b.SelectHash(b.hashBytes(key))
+}
+
+// SelectE is like Select, but a key is required.
+func (b *hashBalancer) SelectE(key ...string) (interface{}, error) {
+	c, err := b.SelectChoice(key...)
+	if err != nil {
+		return nil, err
+	}
+	return c.Item, nil
+}
+
+// SelectChoice is like SelectE, but returns the selected *Choice.
+func (b *hashBalancer) SelectChoice(key ...string) (*Choice, error) {
+	if b.count == 0 {
+		return nil, ErrNoChoices
+	}
+	if len(key) == 0 {
+		return nil, ErrKeyRequired
+	}
+	return b.placement.choose(b.hashKey(key))
+}
+
+// SelectN gets the owner of the key followed by up to n-1 distinct fallbacks,
+// e.g. the replicas of the key. The same key gets the same items as long as the choices do not change.
+func (b *hashBalancer) SelectN(n int, key ...string) []interface{} {
+	return choiceItems(b.selectN(n, key))
+}
+
+// SelectSpread gets the owner of the key followed by up to n-1 distinct fallbacks in other values
+// of the label, e.g. replicas in distinct zones, see SpreadSelector.
+func (b *hashBalancer) SelectSpread(n int, label string, key ...string) []interface{} {
+	return choiceItems(b.selectSpread(n, label, key))
+}
+
+func (b *hashBalancer) selectSpread(n int, label string, key []string) []*Choice {
+	return spread(n, label, b.count, b.labels.get(label, b.items), func(k int) []*Choice {
+		return b.selectN(k, key)
+	})
+}
+
+func (b *hashBalancer) selectN(n int, key []string) []*Choice {
+	if n <= 0 || b.count == 0 {
+		return nil
+	}
+	if n > b.count {
+		n = b.count
+	}
+	return b.placement.chooseN(n, b.hashKey(key))
+}
+
+func (b *hashBalancer) keyOwner(key []string) *Choice {
+	return b.placement.owner(b.hashKey(key))
+}
+
+// Choices returns all items.
+func (b *hashBalancer) Choices() []*Choice {
+	return b.items
+}
+
+// keyHasher hashes the keys of the hash balancers.
+type keyHasher struct {
+	// nil is FNV-1a, streamed over the key parts
+	hasher hasher.Hasher
+}
+
+// hashKey hashes the concatenation of the key parts.
+func (k *keyHasher) hashKey(key []string) uint64 {
+	if k.hasher == nil {
+		return utils.HashString(key...)
+	}
+	if len(key) == 1 {
+		// read-only, see hasher.Hasher
+		return k.hasher.Sum64(utils.S2B(key[0]))
+	}
+	// the built-in hashers by value, for the key not to escape through the interface
+	switch h := k.hasher.(type) {
+	case hasher.FNV1a:
+		return h.Sum64Strings(key...)
+	case hasher.CRC32C:
+		return h.Sum64Strings(key...)
+	case hasher.Murmur3:
+		return h.Sum64Strings(key...)
+	case hasher.XXHash64:
+		return h.Sum64Strings(key...)
+	case hasher.SipHash:
+		return h.Sum64Strings(key...)
+	case hasher.StringsHasher:
+		// a copy, the parts escape through the interface
+		return h.Sum64Strings(append([]string(nil), key...)...)
+	}
+	if len(key) == 0 {
+		return k.hasher.Sum64(nil)
+	}
+	// other hashers need the key in one piece
+	return k.hasher.Sum64(utils.AddStringBytes(key...))
+}
+
+func (k *keyHasher) hashBytes(key []byte) uint64 {
+	if k.hasher == nil {
+		return utils.SumBytes64(key)
 	}
-	return true
+	return k.hasher.Sum64(key)
 }
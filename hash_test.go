@@ -1,9 +1,16 @@
 package balancer
 
 import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
+
+	"github.com/shibingli/load-balancer/hasher"
+	"github.com/shibingli/load-balancer/utils"
 )
 
 func TestConsistentHash(t *testing.T) {
@@ -103,3 +110,185 @@ func TestConsistentHash_C(t *testing.T) {
 		t.Fatalf("hash expected C == 1000000, actual C == %d, item: %s", atomic.LoadInt64(&c), lb.Select("192.168.1.7"))
 	}
 }
+
+func TestConsistentHash_SelectHash(t *testing.T) {
+	lb := NewConsistentHash(NewChoicesSlice([]string{"A", "B", "C", "D", "E"})...)
+	var hs HashSelector = lb
+	for i := 0; i < 100; i++ {
+		a, b := "10.0.0."+strconv.Itoa(i), ":"+strconv.Itoa(i*7)
+		item := lb.Select(a, b)
+		if hs.SelectBytes([]byte(a+b)) != item || hs.SelectHash(utils.Sum64(a+b)) != item {
+			t.Fatalf("hash expected %v for %s%s", item, a, b)
+		}
+	}
+	if NewConsistentHash().SelectBytes([]byte("a")) != nil {
+		t.Fatal("hash expected nil")
+	}
+
+	if utils.HashString("ab", "", "cd") != utils.Sum64("abcd") || utils.SumBytes64([]byte("abcd")) != utils.Sum64("abcd") {
+		t.Fatal("hash of parts wrong")
+	}
+
+	key := []byte("192.168.1.100:443")
+	allocs := testing.AllocsPerRun(100, func() {
+		lb.Select("192.168.1.100", ":443")
+		lb.SelectBytes(key)
+		lb.SelectHash(42)
+	})
+	if allocs != 0 {
+		t.Fatalf("hash expected no allocations, actual %v", allocs)
+	}
+
+	for _, h := range []hasher.Hasher{hasher.FNV1a{}, hasher.CRC32C{}, hasher.Murmur3{}, hasher.XXHash64{}, hasher.SipHash{}} {
+		lb := NewConsistentHashWithHasher(h, NewChoicesSlice([]string{"A", "B", "C", "D", "E"})...)
+		allocs := testing.AllocsPerRun(100, func() {
+			lb.Select("192.168.1.100", ":443")
+			lb.Select("user:", "42", ":session")
+			lb.SelectBytes(key)
+		})
+		if allocs != 0 {
+			t.Fatalf("%T: hash expected no allocations, actual %v", h, allocs)
+		}
+	}
+}
+
+func TestConsistentHash_Hasher(t *testing.T) {
+	choices := NewChoicesSlice([]string{"A", "B", "C", "D", "E"})
+	fnv := NewConsistentHash(choices...)
+	for _, h := range []hasher.Hasher{hasher.FNV1a{}, hasher.XXHash64{}, hasher.Murmur3{}, hasher.CRC32C{}} {
+		lb := NewConsistentHashWithHasher(h, choices...)
+		count := make(map[interface{}]int)
+		same := 0
+		for i := 0; i < 1000; i++ {
+			key := "user:" + strconv.Itoa(i)
+			item := lb.Select(key)
+			count[item]++
+			if lb.Select("user:", strconv.Itoa(i)) != item || lb.SelectBytes([]byte(key)) != item ||
+				lb.SelectHash(h.Sum64([]byte(key))) != item {
+				t.Fatalf("%T: key parts wrong", h)
+			}
+			if fnv.Select(key) == item {
+				same++
+			}
+		}
+		if len(count) != 5 {
+			t.Fatalf("%T: distribution wrong: %v", h, count)
+		}
+		if _, ok := h.(hasher.FNV1a); ok && same != 1000 {
+			t.Fatal("hash expected FNV-1a by default")
+		}
+	}
+}
+
+func TestConsistentHash_MovedKeys(t *testing.T) {
+	current, next := hasher.SipHash{K0: 1, K1: 2}, hasher.SipHash{K0: 3, K1: 4}
+	lb := NewConsistentHashWithHasher(current, NewChoicesSlice([]string{"A", "B", "C", "D"})...)
+
+	keys := make([]string, 1000)
+	owners := make(map[string]interface{}, len(keys))
+	for i := range keys {
+		keys[i] = "session:" + strconv.Itoa(i)
+		owners[keys[i]] = lb.Select(keys[i])
+	}
+	if moved := lb.MovedKeys(current, keys...); len(moved) != 0 {
+		t.Fatal("hash expected no keys to move with the same hasher")
+	}
+
+	moved := lb.MovedKeys(next, keys...)
+	// a new random mapping keeps about a quarter of the keys
+	if len(moved) < 650 || len(moved) > 850 {
+		t.Fatalf("hash expected about 750 keys to move, actual %d", len(moved))
+	}
+
+	lb.SetHasher(next)
+	isMoved := make(map[string]bool, len(moved))
+	for _, key := range moved {
+		isMoved[key] = true
+	}
+	for _, key := range keys {
+		if (lb.Select(key) != owners[key]) != isMoved[key] {
+			t.Fatalf("hash moved keys wrong: %s", key)
+		}
+	}
+
+	if NewConsistentHash().MovedKeys(next, "a") != nil {
+		t.Fatal("hash expected no keys")
+	}
+}
+
+// TestConsistentHash_Vectors checks the placement against testdata/consistent_hash_vectors.json,
+// generated by the reference implementation testdata/consistent_hash_vectors.py.
+func TestConsistentHash_Vectors(t *testing.T) {
+	b, err := os.ReadFile("testdata/consistent_hash_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors struct {
+		Cases []struct {
+			Nodes []string `json:"nodes"`
+			Keys  []struct {
+				Key      string   `json:"key"`
+				Owner    string   `json:"owner"`
+				Replicas []string `json:"replicas"`
+			} `json:"keys"`
+		} `json:"cases"`
+	}
+	if err := json.Unmarshal(b, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors.Cases) == 0 {
+		t.Fatal("hash expected test vectors")
+	}
+
+	for _, c := range vectors.Cases {
+		// any order of the nodes
+		nodes := append([]string(nil), c.Nodes...)
+		for round := 0; round < 3; round++ {
+			lb := NewConsistentHash(NewChoicesSlice(nodes)...)
+			for _, v := range c.Keys {
+				if item := lb.Select(v.Key); item != v.Owner {
+					t.Fatalf("hash of %q expected %s, actual %v", v.Key, v.Owner, item)
+				}
+				replicas := lb.SelectN(3, v.Key)
+				if len(replicas) != len(v.Replicas) {
+					t.Fatalf("hash of %q expected %v, actual %v", v.Key, v.Replicas, replicas)
+				}
+				for i := range replicas {
+					if replicas[i] != v.Replicas[i] {
+						t.Fatalf("hash of %q expected %v, actual %v", v.Key, v.Replicas, replicas)
+					}
+				}
+			}
+			rand.New(rand.NewSource(int64(round))).Shuffle(len(nodes), func(i, j int) {
+				nodes[i], nodes[j] = nodes[j], nodes[i]
+			})
+		}
+	}
+}
+
+func TestConsistentHash_Order(t *testing.T) {
+	m := map[string]int{"A": 1, "B": 1, "C": 1, "D": 1, "E": 1, "F": 1}
+	want := NewConsistentHash(NewChoicesMap(m)...)
+	for i := 0; i < 20; i++ {
+		lb := NewConsistentHash(NewChoicesMap(m)...)
+		for k := 0; k < 100; k++ {
+			key := strconv.Itoa(k)
+			if lb.Select(key) != want.Select(key) {
+				t.Fatal("hash expected the same placement for any order of the choices")
+			}
+		}
+	}
+}
+
+func TestConsistentHash_ID(t *testing.T) {
+	// the placement follows the IDs, not the items
+	a := NewConsistentHash(&Choice{Item: "10.0.0.1", ID: "x"}, &Choice{Item: "10.0.0.2", ID: "y"}, &Choice{Item: "10.0.0.3", ID: "z"})
+	b := NewConsistentHash(&Choice{Item: "10.1.0.3", ID: "z"}, &Choice{Item: "10.1.0.1", ID: "x"}, &Choice{Item: "10.1.0.2", ID: "y"})
+	for i := 0; i < 100; i++ {
+		ca, _ := a.SelectChoice(strconv.Itoa(i))
+		cb, _ := b.SelectChoice(strconv.Itoa(i))
+		if ca.Key() != cb.Key() {
+			t.Fatal("hash expected the same placement for the same IDs")
+		}
+	}
+}
@@ -0,0 +1,342 @@
+// Package hasher provides the key hashes of the consistent hash balancers, in pure Go.
+package hasher
+
+import (
+	"hash/crc32"
+	"math/bits"
+
+	"github.com/shibingli/load-balancer/utils"
+)
+
+// Hasher hashes a key to 64 bits. Implementations must be goroutine-safe.
+type Hasher interface {
+	// Sum64 must not modify or retain key: the balancers pass the memory of the key string,
+	// which is read-only.
+	Sum64(key []byte) uint64
+}
+
+// StringsHasher is implemented by the hashers of the package, to hash a key given in parts
+// without concatenating them, e.g. the key parts of Select.
+type StringsHasher interface {
+	// Sum64Strings returns the Sum64 of the concatenation of the parts.
+	Sum64Strings(parts ...string) uint64
+}
+
+// FNV1a is the 64-bit FNV-1a hash, the default of the balancers.
+// It is fast on short keys, but similar keys get similar hashes.
+type FNV1a struct{}
+
+func (FNV1a) Sum64(key []byte) uint64 {
+	return utils.SumBytes64(key)
+}
+
+func (FNV1a) Sum64Strings(parts ...string) uint64 {
+	return utils.HashString(parts...)
+}
+
+// CRC32C is the CRC-32 with the Castagnoli polynomial, hardware accelerated on most CPUs.
+// It is a 32-bit checksum, not a hash: use it to match other systems, not for its distribution.
+type CRC32C struct{}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func (CRC32C) Sum64(key []byte) uint64 {
+	return uint64(crc32.Checksum(key, castagnoli))
+}
+
+func (CRC32C) Sum64Strings(parts ...string) uint64 {
+	var crc uint32
+	for _, s := range parts {
+		crc = crc32.Update(crc, castagnoli, utils.S2B(s))
+	}
+	return uint64(crc)
+}
+
+// Murmur3 is MurmurHash3 x64_128.
+// Ref: https://github.com/aappleby/smhasher/blob/master/src/MurmurHash3.cpp
+type Murmur3 struct {
+	Seed uint32
+}
+
+// Sum64 returns the 128-bit hash folded to 64 bits, h1 ^ h2.
+func (m Murmur3) Sum64(key []byte) uint64 {
+	h1, h2 := m.Sum128(key)
+	return h1 ^ h2
+}
+
+// Sum128 returns both halves of the hash, h1 is e.g. asLong() of Guava's murmur3_128.
+func (m Murmur3) Sum128(key []byte) (h1, h2 uint64) {
+	h1, h2 = uint64(m.Seed), uint64(m.Seed)
+	n := len(key)
+	for ; len(key) >= 16; key = key[16:] {
+		h1, h2 = murmurBlock(h1, h2, key)
+	}
+	return murmurFinish(h1, h2, key, n)
+}
+
+func (m Murmur3) Sum64Strings(parts ...string) uint64 {
+	p := newParts(parts)
+	h1, h2 := uint64(m.Seed), uint64(m.Seed)
+	n := p.n
+	var buf [16]byte
+	for p.n >= 16 {
+		p.read(buf[:])
+		h1, h2 = murmurBlock(h1, h2, buf[:])
+	}
+	tail := buf[:p.n]
+	p.read(tail)
+	h1, h2 = murmurFinish(h1, h2, tail, n)
+	return h1 ^ h2
+}
+
+const (
+	murmurC1 = 0x87c37b91114253d5
+	murmurC2 = 0x4cf5ad432745937f
+)
+
+// murmurBlock mixes the 16-byte block b.
+func murmurBlock(h1, h2 uint64, b []byte) (uint64, uint64) {
+	k1, k2 := le64(b), le64(b[8:])
+
+	k1 *= murmurC1
+	k1 = bits.RotateLeft64(k1, 31)
+	k1 *= murmurC2
+	h1 ^= k1
+	h1 = bits.RotateLeft64(h1, 27)
+	h1 += h2
+	h1 = h1*5 + 0x52dce729
+
+	k2 *= murmurC2
+	k2 = bits.RotateLeft64(k2, 33)
+	k2 *= murmurC1
+	h2 ^= k2
+	h2 = bits.RotateLeft64(h2, 31)
+	h2 += h1
+	h2 = h2*5 + 0x38495ab5
+	return h1, h2
+}
+
+// murmurFinish mixes the tail of less than 16 bytes of a key of n bytes.
+func murmurFinish(h1, h2 uint64, tail []byte, n int) (uint64, uint64) {
+	var k1, k2 uint64
+	switch len(tail) {
+	case 15:
+		k2 ^= uint64(tail[14]) << 48
+		fallthrough
+	case 14:
+		k2 ^= uint64(tail[13]) << 40
+		fallthrough
+	case 13:
+		k2 ^= uint64(tail[12]) << 32
+		fallthrough
+	case 12:
+		k2 ^= uint64(tail[11]) << 24
+		fallthrough
+	case 11:
+		k2 ^= uint64(tail[10]) << 16
+		fallthrough
+	case 10:
+		k2 ^= uint64(tail[9]) << 8
+		fallthrough
+	case 9:
+		k2 ^= uint64(tail[8])
+		k2 *= murmurC2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= murmurC1
+		h2 ^= k2
+		fallthrough
+	case 8:
+		k1 ^= uint64(tail[7]) << 56
+		fallthrough
+	case 7:
+		k1 ^= uint64(tail[6]) << 48
+		fallthrough
+	case 6:
+		k1 ^= uint64(tail[5]) << 40
+		fallthrough
+	case 5:
+		k1 ^= uint64(tail[4]) << 32
+		fallthrough
+	case 4:
+		k1 ^= uint64(tail[3]) << 24
+		fallthrough
+	case 3:
+		k1 ^= uint64(tail[2]) << 16
+		fallthrough
+	case 2:
+		k1 ^= uint64(tail[1]) << 8
+		fallthrough
+	case 1:
+		k1 ^= uint64(tail[0])
+		k1 *= murmurC1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= murmurC2
+		h1 ^= k1
+	}
+
+	h1 ^= uint64(n)
+	h2 ^= uint64(n)
+	h1 += h2
+	h2 += h1
+	h1, h2 = fmix64(h1), fmix64(h2)
+	h1 += h2
+	h2 += h1
+	return h1, h2
+}
+
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+// XXHash64 is xxHash64, fast on long keys with a good distribution.
+// Ref: https://github.com/Cyan4973/xxHash/blob/dev/doc/xxhash_spec.md
+type XXHash64 struct {
+	Seed uint64
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+func (x XXHash64) Sum64(key []byte) uint64 {
+	n := len(key)
+	var h uint64
+	if n >= 32 {
+		v := x.init()
+		for ; len(key) >= 32; key = key[32:] {
+			xxStripe(&v, key)
+		}
+		h = xxFold(&v)
+	} else {
+		h = x.Seed + xxPrime5
+	}
+	return xxFinish(h+uint64(n), key)
+}
+
+func (x XXHash64) Sum64Strings(parts ...string) uint64 {
+	p := newParts(parts)
+	n := p.n
+	var (
+		buf [32]byte
+		h   uint64
+	)
+	if n >= 32 {
+		v := x.init()
+		for p.n >= 32 {
+			p.read(buf[:])
+			xxStripe(&v, buf[:])
+		}
+		h = xxFold(&v)
+	} else {
+		h = x.Seed + xxPrime5
+	}
+	tail := buf[:p.n]
+	p.read(tail)
+	return xxFinish(h+uint64(n), tail)
+}
+
+// init returns the accumulators of the keys of 32 bytes or more.
+func (x XXHash64) init() [4]uint64 {
+	return [4]uint64{x.Seed + xxPrime1 + xxPrime2, x.Seed + xxPrime2, x.Seed, x.Seed - xxPrime1}
+}
+
+// xxStripe mixes the 32-byte stripe b.
+func xxStripe(v *[4]uint64, b []byte) {
+	v[0] = xxRound(v[0], le64(b))
+	v[1] = xxRound(v[1], le64(b[8:]))
+	v[2] = xxRound(v[2], le64(b[16:]))
+	v[3] = xxRound(v[3], le64(b[24:]))
+}
+
+func xxFold(v *[4]uint64) uint64 {
+	h := bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) + bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+	h = xxMerge(h, v[0])
+	h = xxMerge(h, v[1])
+	h = xxMerge(h, v[2])
+	return xxMerge(h, v[3])
+}
+
+// xxFinish mixes the tail of less than 32 bytes and avalanches h.
+func xxFinish(h uint64, tail []byte) uint64 {
+	for ; len(tail) >= 8; tail = tail[8:] {
+		h ^= xxRound(0, le64(tail))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(tail) >= 4 {
+		h ^= uint64(le32(tail)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		tail = tail[4:]
+	}
+	for _, b := range tail {
+		h ^= uint64(b) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMerge(acc, v uint64) uint64 {
+	acc ^= xxRound(0, v)
+	return acc*xxPrime1 + xxPrime4
+}
+
+func le64(b []byte) uint64 {
+	_ = b[7]
+	return uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16 | uint64(b[3])<<24 |
+		uint64(b[4])<<32 | uint64(b[5])<<40 | uint64(b[6])<<48 | uint64(b[7])<<56
+}
+
+func le32(b []byte) uint32 {
+	_ = b[3]
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+// parts reads the concatenation of strings, for the hashers that work on blocks.
+type parts struct {
+	s   []string
+	off int
+	// n is the number of bytes left
+	n int
+}
+
+func newParts(s []string) parts {
+	p := parts{s: s}
+	for i := range s {
+		p.n += len(s[i])
+	}
+	return p
+}
+
+// read fills b with the next len(b) bytes, at most n.
+func (p *parts) read(b []byte) {
+	for len(b) > 0 {
+		if p.off == len(p.s[0]) {
+			p.s, p.off = p.s[1:], 0
+			continue
+		}
+		c := copy(b, p.s[0][p.off:])
+		b = b[c:]
+		p.off += c
+		p.n -= c
+	}
+}
@@ -0,0 +1,210 @@
+package hasher
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	jump "github.com/shibingli/load-balancer/internal/go-jump"
+)
+
+// reference values of github.com/cespare/xxhash/v2, github.com/spaolacci/murmur3 and hash/crc32
+var vectors = []struct {
+	key              string
+	xxhash           uint64
+	murmur1, murmur2 uint64
+	seeded1, seeded2 uint64 // murmur3 with seed 42
+	crc32c           uint32
+}{
+	{"", 0xef46db3751d8e999, 0x0000000000000000, 0x0000000000000000, 0xf02aa77dfa1b8523, 0xd1016610da11cbb9, 0x00000000},
+	{"a", 0xd24ec4f1a98c6e5b, 0x85555565f6597889, 0xe6b53a48510e895a, 0x28259ca4fdf626b0, 0x25ebca9125f82b15, 0xc1d04330},
+	{"abc", 0x44bc2cf5ad770999, 0xb4963f3f3fad7867, 0x3ba2744126ca2d52, 0x0d85089fb3cff7d6, 0x7510712b42353d30, 0x364b3fb7},
+	{"hello, world", 0xb33a384e6d1b1242, 0x342fac623a5ebc8e, 0x4cdcbc079642414d, 0xb91864d797caa956, 0xd5d139a55afe6150, 0x6999a41f},
+	{"0123456789abcdef", 0x5c5b90c34e376d0b, 0x4be06d94cf4ad1a7, 0x87c35b5c63a708da, 0x818ea26bed3cb2a4, 0xf604d245f9269fde, 0x42d3119e},
+	{"user:1234567", 0x1b53dc4fc55896e3, 0x112ae2c1601282b5, 0x2319a0dd3929fa21, 0xc91bcd5821b44fb1, 0x16fe88088100fd04, 0x21df4a42},
+	{strings.Repeat("xyz", 30), 0xdb30298efb0408c1, 0x26b0a72c413e57ab, 0x46c75e8585848fe3, 0xf40ddbda48442668, 0x09844415bc172dec, 0x5630f1f7},
+}
+
+func TestVectors(t *testing.T) {
+	for _, v := range vectors {
+		key := []byte(v.key)
+		if h := (XXHash64{}).Sum64(key); h != v.xxhash {
+			t.Fatalf("xxhash %q: expected %x, actual %x", v.key, v.xxhash, h)
+		}
+		if h1, h2 := (Murmur3{}).Sum128(key); h1 != v.murmur1 || h2 != v.murmur2 {
+			t.Fatalf("murmur3 %q: expected %x %x, actual %x %x", v.key, v.murmur1, v.murmur2, h1, h2)
+		}
+		if h := (Murmur3{}).Sum64(key); h != v.murmur1^v.murmur2 {
+			t.Fatalf("murmur3 %q: fold wrong", v.key)
+		}
+		if h1, h2 := (Murmur3{Seed: 42}).Sum128(key); h1 != v.seeded1 || h2 != v.seeded2 {
+			t.Fatalf("murmur3 seed %q: expected %x %x, actual %x %x", v.key, v.seeded1, v.seeded2, h1, h2)
+		}
+		if h := (CRC32C{}).Sum64(key); h != uint64(v.crc32c) {
+			t.Fatalf("crc32c %q: expected %x, actual %x", v.key, v.crc32c, h)
+		}
+	}
+
+	if h := (FNV1a{}).Sum64([]byte("a")); h != 0xaf63dc4c8601ec8c {
+		t.Fatalf("fnv1a expected af63dc4c8601ec8c, actual %x", h)
+	}
+	if (XXHash64{Seed: 1}).Sum64([]byte("a")) == (XXHash64{}).Sum64([]byte("a")) {
+		t.Fatal("xxhash expected the seed to change the hash")
+	}
+}
+
+var hashers = []struct {
+	name string
+	h    Hasher
+	// strong hashes pass the avalanche test
+	strong bool
+}{
+	{"fnv1a", FNV1a{}, false},
+	{"crc32c", CRC32C{}, false},
+	{"murmur3", Murmur3{}, true},
+	{"xxhash", XXHash64{}, true},
+	{"siphash", SipHash{K0: 1, K1: 2}, true},
+}
+
+func TestSum64Strings(t *testing.T) {
+	key := strings.Repeat("0123456789abcdefghij", 5)
+	for _, v := range hashers {
+		sh, ok := v.h.(StringsHasher)
+		if !ok {
+			t.Fatalf("%s expected a StringsHasher", v.name)
+		}
+		if sh.Sum64Strings() != v.h.Sum64(nil) {
+			t.Fatalf("%s: hash of no parts wrong", v.name)
+		}
+		for n := 0; n <= len(key); n++ {
+			want := v.h.Sum64([]byte(key[:n]))
+			// every split in two and three parts, with empty ones
+			for i := 0; i <= n; i++ {
+				if h := sh.Sum64Strings(key[:i], key[i:n]); h != want {
+					t.Fatalf("%s: hash of %d bytes split at %d wrong", v.name, n, i)
+				}
+				j := (i + n) / 2
+				if h := sh.Sum64Strings(key[:i], "", key[i:j], key[j:n]); h != want {
+					t.Fatalf("%s: hash of %d bytes split at %d, %d wrong", v.name, n, i, j)
+				}
+			}
+		}
+	}
+}
+
+// TestDistribution hashes sequential keys into buckets and checks the chi-square statistic.
+func TestDistribution(t *testing.T) {
+	const (
+		keys    = 100000
+		buckets = 64
+	)
+	for _, v := range hashers {
+		for _, prefix := range []string{"user:", "10.0.", ""} {
+			count := make([]int, buckets)
+			for i := 0; i < keys; i++ {
+				h := v.h.Sum64([]byte(prefix + strconv.Itoa(i)))
+				// the high bits, as a jump hash would use them
+				count[jump.Hash(h, buckets)]++
+			}
+
+			chi, expected := 0.0, float64(keys)/buckets
+			for _, n := range count {
+				d := float64(n) - expected
+				chi += d * d / expected
+			}
+			// 63 degrees of freedom, p = 0.001
+			if chi > 103.4 {
+				t.Errorf("%s %q: chi-square %.1f", v.name, prefix, chi)
+			}
+		}
+	}
+}
+
+// TestAvalanche flips each bit of random keys and checks that about half of the hash bits change.
+func TestAvalanche(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, v := range hashers {
+		for _, size := range []int{4, 8, 16, 40} {
+			const rounds = 1000
+			flips := make([][64]int, size*8)
+			key := make([]byte, size)
+			for round := 0; round < rounds; round++ {
+				r.Read(key)
+				h := v.h.Sum64(key)
+				for i := range flips {
+					key[i/8] ^= 1 << (i % 8)
+					d := h ^ v.h.Sum64(key)
+					key[i/8] ^= 1 << (i % 8)
+					for j := 0; j < 64; j++ {
+						flips[i][j] += int(d >> j & 1)
+					}
+				}
+			}
+
+			// the worst bias of an input bit on an output bit
+			worst := 0.0
+			for i := range flips {
+				for j := range flips[i] {
+					worst = math.Max(worst, math.Abs(float64(flips[i][j])/rounds-0.5))
+				}
+			}
+			if v.strong && worst > 0.1 {
+				t.Errorf("%s %d bytes: avalanche bias %.2f", v.name, size, worst)
+			}
+			if !v.strong {
+				t.Logf("%s %d bytes: avalanche bias %.2f", v.name, size, worst)
+			}
+		}
+	}
+}
+
+// TestSimilarKeys checks that keys differing in one byte spread over the buckets.
+func TestSimilarKeys(t *testing.T) {
+	for _, v := range hashers {
+		if !v.strong {
+			continue
+		}
+		seen := make(map[int32]bool)
+		key := make([]byte, 8)
+		for i := 0; i < 256; i++ {
+			binary.BigEndian.PutUint64(key, uint64(i))
+			seen[jump.Hash(v.h.Sum64(key), 1024)] = true
+		}
+		// 256 keys into 1024 buckets, about 226 distinct buckets are expected
+		if len(seen) < 200 {
+			t.Errorf("%s: %d distinct buckets", v.name, len(seen))
+		}
+	}
+}
+
+func TestGoroutineSafe(t *testing.T) {
+	for _, v := range hashers {
+		done := make(chan uint64)
+		for i := 0; i < 4; i++ {
+			go func() { done <- v.h.Sum64([]byte("key")) }()
+		}
+		h := <-done
+		for i := 1; i < 4; i++ {
+			if <-done != h {
+				t.Fatalf("%s: not goroutine-safe", v.name)
+			}
+		}
+	}
+}
+
+func BenchmarkHasher(b *testing.B) {
+	for _, size := range []int{8, 32, 256} {
+		key := make([]byte, size)
+		for _, v := range hashers {
+			b.Run(v.name+"-"+strconv.Itoa(size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				for i := 0; i < b.N; i++ {
+					v.h.Sum64(key)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math/bits"
+)
+
+// SipHash is the keyed SipHash-2-4. Unlike the unkeyed hashes, keys chosen by clients cannot be
+// crafted to land on one item without knowing the secret key.
+// Ref: https://www.aumasson.jp/siphash/siphash.pdf
+type SipHash struct {
+	K0, K1 uint64
+}
+
+// NewSipHash create a SipHash with the 128-bit secret key.
+func NewSipHash(key [16]byte) SipHash {
+	return SipHash{
+		K0: binary.LittleEndian.Uint64(key[:8]),
+		K1: binary.LittleEndian.Uint64(key[8:]),
+	}
+}
+
+// NewRandomSipHash create a SipHash with a random secret key.
+func NewRandomSipHash() (SipHash, error) {
+	var key [16]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return SipHash{}, err
+	}
+	return NewSipHash(key), nil
+}
+
+func (s SipHash) Sum64(key []byte) uint64 {
+	v := s.init()
+	n := len(key)
+	for ; len(key) >= 8; key = key[8:] {
+		sipBlock(&v, le64(key))
+	}
+	return sipFinish(&v, key, n)
+}
+
+func (s SipHash) Sum64Strings(parts ...string) uint64 {
+	p := newParts(parts)
+	v := s.init()
+	n := p.n
+	var buf [8]byte
+	for p.n >= 8 {
+		p.read(buf[:])
+		sipBlock(&v, le64(buf[:]))
+	}
+	tail := buf[:p.n]
+	p.read(tail)
+	return sipFinish(&v, tail, n)
+}
+
+func (s SipHash) init() [4]uint64 {
+	return [4]uint64{
+		s.K0 ^ 0x736f6d6570736575,
+		s.K1 ^ 0x646f72616e646f6d,
+		s.K0 ^ 0x6c7967656e657261,
+		s.K1 ^ 0x7465646279746573,
+	}
+}
+
+// sipBlock compresses the 8-byte word m.
+func sipBlock(v *[4]uint64, m uint64) {
+	v[3] ^= m
+	v[0], v[1], v[2], v[3] = sipRound(v[0], v[1], v[2], v[3])
+	v[0], v[1], v[2], v[3] = sipRound(v[0], v[1], v[2], v[3])
+	v[0] ^= m
+}
+
+// sipFinish compresses the tail of less than 8 bytes of a key of n bytes and finalizes.
+func sipFinish(v *[4]uint64, tail []byte, n int) uint64 {
+	m := uint64(n) << 56
+	for i := len(tail) - 1; i >= 0; i-- {
+		m |= uint64(tail[i]) << (8 * i)
+	}
+	sipBlock(v, m)
+
+	v[2] ^= 0xff
+	for i := 0; i < 4; i++ {
+		v[0], v[1], v[2], v[3] = sipRound(v[0], v[1], v[2], v[3])
+	}
+	return v[0] ^ v[1] ^ v[2] ^ v[3]
+}
+
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v1 = bits.RotateLeft64(v1, 13)
+	v1 ^= v0
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v3
+	v3 = bits.RotateLeft64(v3, 16)
+	v3 ^= v2
+	v0 += v3
+	v3 = bits.RotateLeft64(v3, 21)
+	v3 ^= v0
+	v2 += v1
+	v1 = bits.RotateLeft64(v1, 17)
+	v1 ^= v2
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
+}
@@ -0,0 +1,51 @@
+package hasher
+
+import (
+	"strings"
+	"testing"
+)
+
+// reference values of github.com/dchest/siphash
+var sipVectors = []struct {
+	key    string
+	ref    uint64 // key 00 01 .. 0f
+	seeded uint64 // K0 1, K1 2
+}{
+	{"", 0x726fdb47dd0e0e31, 0x8628af35e1cba77b},
+	{"a", 0x2ba3e8e9a71148ca, 0x4795cb2ed678fcf6},
+	{"abc", 0x5dbcfa53aa2007a5, 0xd15ad05b2871319d},
+	{"hello, world", 0x5222c673f3faebb2, 0xee57ea62fd05166b},
+	{"0123456789abcdef", 0xf1e5cc3e61b4ecbd, 0xa56b8a9419e12464},
+	{"user:1234567", 0xfa9033a1f82bb608, 0xdc96f13967afeee3},
+	{strings.Repeat("xyz", 30), 0x032f7d2b6ba488a0, 0xe1928b633c23aaf6},
+}
+
+func TestSipHash(t *testing.T) {
+	var key [16]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+	ref := NewSipHash(key)
+	for _, v := range sipVectors {
+		if h := ref.Sum64([]byte(v.key)); h != v.ref {
+			t.Fatalf("siphash %q: expected %x, actual %x", v.key, v.ref, h)
+		}
+		if h := (SipHash{K0: 1, K1: 2}).Sum64([]byte(v.key)); h != v.seeded {
+			t.Fatalf("siphash %q: expected %x, actual %x", v.key, v.seeded, h)
+		}
+	}
+
+	// the test vector of the paper
+	if h := ref.Sum64(key[:15]); h != 0xa129ca6149be45e5 {
+		t.Fatalf("siphash expected a129ca6149be45e5, actual %x", h)
+	}
+
+	a, err := NewRandomSipHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewRandomSipHash()
+	if a == b || a.Sum64([]byte("a")) == b.Sum64([]byte("a")) {
+		t.Fatal("siphash expected random keys")
+	}
+}
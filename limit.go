@@ -0,0 +1,154 @@
+package balancer
+
+import (
+	"math"
+	"time"
+)
+
+// Limiter is an adaptive concurrency limit of one choice, see Cluster.SetLimiter.
+// Calls are serialized per choice, implementations need not be goroutine-safe.
+type Limiter interface {
+	// Limit returns the current limit, at least 1.
+	Limit() int
+
+	// Sample records a finished request: rtt is its latency, inflight the number of requests
+	// in flight when it was selected, dropped whether it failed.
+	Sample(rtt time.Duration, inflight int, dropped bool)
+}
+
+// AIMD is an additive-increase / multiplicative-decrease Limiter.
+// The limit grows by one per successful sample while it is in use, and backs off on errors
+// or on latency above Timeout.
+// Ref: https://github.com/Netflix/concurrency-limits
+type AIMD struct {
+	// MinLimit, MaxLimit bound the limit, default 1 and 200.
+	MinLimit, MaxLimit int
+
+	// BackoffRatio is applied to the limit on a drop, default 0.9.
+	BackoffRatio float64
+
+	// Timeout counts slower requests as dropped, optional.
+	Timeout time.Duration
+
+	limit float64
+}
+
+// NewAIMD create an AIMD limiter starting at the initial limit.
+func NewAIMD(initial int) *AIMD {
+	l := &AIMD{MinLimit: 1, MaxLimit: 200, BackoffRatio: 0.9}
+	l.limit = clampLimit(float64(initial), l.MinLimit, l.MaxLimit)
+	return l
+}
+
+// Limit returns the current limit, the zero fields of an AIMD{} get their defaults.
+func (l *AIMD) Limit() int {
+	return int(clampLimit(l.limit, l.MinLimit, l.MaxLimit))
+}
+
+func (l *AIMD) Sample(rtt time.Duration, inflight int, dropped bool) {
+	limit := clampLimit(l.limit, l.MinLimit, l.MaxLimit)
+	switch {
+	case dropped || (l.Timeout > 0 && rtt > l.Timeout):
+		ratio := l.BackoffRatio
+		if ratio <= 0 {
+			ratio = 0.9
+		}
+		limit = math.Floor(limit * ratio)
+	case inflight*2 >= int(limit):
+		// only grow a limit that is actually used
+		limit++
+	default:
+		return
+	}
+	l.limit = clampLimit(limit, l.MinLimit, l.MaxLimit)
+}
+
+// Gradient is a Limiter that compares the latency of each request with the long-term average:
+// the limit grows while latency stays flat and shrinks as latency inflates or requests fail.
+// Ref: https://github.com/Netflix/concurrency-limits (Gradient2Limit)
+type Gradient struct {
+	// MinLimit, MaxLimit bound the limit, default 1 and 200.
+	MinLimit, MaxLimit int
+
+	// Tolerance of latency inflation before the limit shrinks, default 1.5.
+	Tolerance float64
+
+	// Smoothing of limit changes in (0, 1], default 0.2.
+	Smoothing float64
+
+	// Window is the number of samples of the long-term latency average, default 600.
+	Window int
+
+	limit   float64
+	longRTT float64
+}
+
+// NewGradient create a Gradient limiter starting at the initial limit.
+func NewGradient(initial int) *Gradient {
+	l := &Gradient{MinLimit: 1, MaxLimit: 200, Tolerance: 1.5, Smoothing: 0.2, Window: 600}
+	l.limit = clampLimit(float64(initial), l.MinLimit, l.MaxLimit)
+	return l
+}
+
+// Limit returns the current limit, the zero fields of a Gradient{} get their defaults.
+func (l *Gradient) Limit() int {
+	return int(clampLimit(l.limit, l.MinLimit, l.MaxLimit))
+}
+
+func (l *Gradient) Sample(rtt time.Duration, inflight int, dropped bool) {
+	tolerance, smoothing, window := l.Tolerance, l.Smoothing, l.Window
+	if tolerance <= 0 {
+		tolerance = 1.5
+	}
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+	if window <= 0 {
+		window = 600
+	}
+	l.limit = clampLimit(l.limit, l.MinLimit, l.MaxLimit)
+
+	short := float64(rtt)
+	if short <= 0 {
+		short = 1
+	}
+	if l.longRTT == 0 {
+		l.longRTT = short
+	}
+	l.longRTT += (short - l.longRTT) / float64(window)
+	// let the average recover quickly after a period of high latency
+	if l.longRTT/short > 2 {
+		l.longRTT *= 0.95
+	}
+
+	gradient, queue := 0.5, 0.0
+	if !dropped {
+		if inflight*2 < int(l.limit) {
+			// the limit is not in use, there is nothing to learn
+			return
+		}
+		gradient = math.Max(0.5, math.Min(1, tolerance*l.longRTT/short))
+		queue = math.Sqrt(l.limit)
+	}
+
+	limit := l.limit*gradient + queue
+	limit = l.limit*(1-smoothing) + limit*smoothing
+	l.limit = clampLimit(limit, l.MinLimit, l.MaxLimit)
+}
+
+// clampLimit bounds n to [min, max], the default bounds are 1 and 200.
+func clampLimit(n float64, min, max int) float64 {
+	if min < 1 {
+		min = 1
+	}
+	if max <= 0 {
+		max = 200
+	}
+	if n < float64(min) {
+		return float64(min)
+	}
+	if n > float64(max) && max >= min {
+		return float64(max)
+	}
+	return n
+}
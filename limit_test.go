@@ -0,0 +1,162 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAIMD(t *testing.T) {
+	l := NewAIMD(10)
+	if l.Limit() != 10 {
+		t.Fatalf("aimd expected 10, actual %d", l.Limit())
+	}
+
+	// an unused limit does not grow
+	l.Sample(time.Millisecond, 1, false)
+	if l.Limit() != 10 {
+		t.Fatalf("aimd expected 10, actual %d", l.Limit())
+	}
+	for i := 0; i < 5; i++ {
+		l.Sample(time.Millisecond, 10, false)
+	}
+	if l.Limit() != 15 {
+		t.Fatalf("aimd expected 15, actual %d", l.Limit())
+	}
+
+	l.Sample(time.Millisecond, 15, true)
+	if l.Limit() != 13 {
+		t.Fatalf("aimd expected 13, actual %d", l.Limit())
+	}
+	l.Timeout = 100 * time.Millisecond
+	l.Sample(time.Second, 13, false)
+	if l.Limit() != 11 {
+		t.Fatalf("aimd expected 11, actual %d", l.Limit())
+	}
+
+	for i := 0; i < 100; i++ {
+		l.Sample(time.Millisecond, 1, true)
+	}
+	if l.Limit() != 1 {
+		t.Fatalf("aimd expected 1, actual %d", l.Limit())
+	}
+	for i := 0; i < 1000; i++ {
+		l.Sample(time.Millisecond, l.Limit(), false)
+	}
+	if l.Limit() != 200 {
+		t.Fatalf("aimd expected 200, actual %d", l.Limit())
+	}
+}
+
+func TestGradient(t *testing.T) {
+	l := NewGradient(10)
+	for i := 0; i < 200; i++ {
+		l.Sample(10*time.Millisecond, l.Limit(), false)
+	}
+	grown := l.Limit()
+	if grown <= 10 {
+		t.Fatalf("gradient expected to grow, actual %d", grown)
+	}
+
+	for i := 0; i < 50; i++ {
+		l.Sample(50*time.Millisecond, l.Limit(), false)
+	}
+	if l.Limit() >= grown {
+		t.Fatalf("gradient expected to shrink on latency, actual %d >= %d", l.Limit(), grown)
+	}
+
+	before := l.Limit()
+	for i := 0; i < 10; i++ {
+		l.Sample(10*time.Millisecond, l.Limit(), true)
+	}
+	if l.Limit() >= before {
+		t.Fatalf("gradient expected to shrink on errors, actual %d >= %d", l.Limit(), before)
+	}
+
+	for i := 0; i < 1000; i++ {
+		l.Sample(10*time.Millisecond, 1, true)
+	}
+	if l.Limit() != 1 {
+		t.Fatalf("gradient expected 1, actual %d", l.Limit())
+	}
+}
+
+func TestLimiter_ZeroValue(t *testing.T) {
+	// the zero fields get their defaults
+	for _, l := range []Limiter{&AIMD{}, &Gradient{}} {
+		if l.Limit() != 1 {
+			t.Fatalf("%T expected 1, actual %d", l, l.Limit())
+		}
+		for i := 0; i < 2000; i++ {
+			l.Sample(10*time.Millisecond, l.Limit(), false)
+		}
+		if l.Limit() != 200 {
+			t.Fatalf("%T expected 200, actual %d", l, l.Limit())
+		}
+		for i := 0; i < 1000; i++ {
+			l.Sample(10*time.Millisecond, 1, true)
+		}
+		if l.Limit() != 1 {
+			t.Fatalf("%T expected 1, actual %d", l, l.Limit())
+		}
+	}
+}
+
+func TestCluster_SetLimiter(t *testing.T) {
+	a := &Choice{Item: "A", Weight: 1, MaxConcurrency: 5}
+	b := &Choice{Item: "B", Weight: 1}
+	c := NewCluster(NewRoundRobin(), a, b)
+	c.SetLimiter(func() Limiter { return NewAIMD(2) })
+	if a.Limit() != 2 || b.Limit() != 2 {
+		t.Fatal("cluster SetLimiter wrong")
+	}
+
+	ctx := context.Background()
+	var leases []*Lease
+	for i := 0; i < 4; i++ {
+		l, err := c.SelectContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leases = append(leases, l)
+	}
+	if _, err := c.SelectE(); !errors.Is(err, ErrSaturated) {
+		t.Fatalf("cluster expected ErrSaturated, actual %v", err)
+	}
+
+	// success grows, the static MaxConcurrency still caps A
+	for _, l := range leases {
+		l.Done(nil)
+	}
+	for i := 0; i < 10; i++ {
+		leases = leases[:0]
+		for j := a.Limit() + b.Limit(); j > 0; j-- {
+			l, err := c.SelectContext(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			leases = append(leases, l)
+		}
+		for _, l := range leases {
+			l.Done(nil)
+		}
+	}
+	if a.Limit() != 5 || b.Limit() <= 2 {
+		t.Fatalf("cluster limit wrong: %d, %d", a.Limit(), b.Limit())
+	}
+
+	limit := b.Limit()
+	for i := 0; i < 4; i++ {
+		l, _ := c.SelectContext(ctx)
+		l.Done(errors.New("timeout"))
+	}
+	if b.Limit() >= limit {
+		t.Fatalf("cluster limit expected to shrink, actual %d", b.Limit())
+	}
+
+	c.SetLimiter(nil)
+	if a.Limit() != 5 || b.Limit() != 0 {
+		t.Fatal("cluster SetLimiter(nil) wrong")
+	}
+}
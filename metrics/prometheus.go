@@ -0,0 +1,195 @@
+// Package metrics exports the Stats of balancer clusters in the Prometheus text exposition format,
+// without depending on the Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	balancer "github.com/shibingli/load-balancer"
+)
+
+// ContentType of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Source of stats, e.g. *balancer.Cluster.
+type Source interface {
+	Stats() balancer.Stats
+}
+
+// Handler is an http.Handler that renders the stats of the registered sources.
+type Handler struct {
+	mu      sync.RWMutex
+	sources []Source
+}
+
+// NewHandler create a handler of the sources.
+func NewHandler(sources ...Source) *Handler {
+	return &Handler{sources: sources}
+}
+
+// Register adds a source. The balancer label is its Name(), so register balancers with distinct names.
+func (h *Handler) Register(s Source) {
+	h.mu.Lock()
+	h.sources = append(h.sources, s)
+	h.mu.Unlock()
+}
+
+// Unregister removes a source.
+func (h *Handler) Unregister(s Source) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.sources {
+		if h.sources[i] == s {
+			h.sources = append(h.sources[:i:i], h.sources[i+1:]...)
+			return
+		}
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	h.mu.RLock()
+	stats := make([]balancer.Stats, len(h.sources))
+	for i := range h.sources {
+		stats[i] = h.sources[i].Stats()
+	}
+	h.mu.RUnlock()
+
+	w.Header().Set("Content-Type", ContentType)
+	_ = WriteText(w, stats...)
+}
+
+type family struct {
+	name  string
+	help  string
+	typ   string
+	value func(s *balancer.Stats, c *balancer.ChoiceStats) float64
+}
+
+var balancerFamilies = []family{
+	{"balancer_selections_total", "Total number of successful selections.", "counter",
+		func(s *balancer.Stats, _ *balancer.ChoiceStats) float64 { return float64(s.Selections) }},
+	{"balancer_selection_errors_total", "Total number of selections that returned an error.", "counter",
+		func(s *balancer.Stats, _ *balancer.ChoiceStats) float64 { return float64(s.Errors) }},
+}
+
+var choiceFamilies = []family{
+	{"balancer_choice_selections_total", "Total number of times the choice has been selected.", "counter",
+		func(_ *balancer.Stats, c *balancer.ChoiceStats) float64 { return float64(c.Selections) }},
+	{"balancer_choice_weight", "Configured weight of the choice.", "gauge",
+		func(_ *balancer.Stats, c *balancer.ChoiceStats) float64 { return float64(c.Weight) }},
+	{"balancer_choice_effective_weight", "Weight of the choice, 0 while ejected.", "gauge",
+		func(_ *balancer.Stats, c *balancer.ChoiceStats) float64 { return float64(c.EffectiveWeight) }},
+	{"balancer_choice_current_weight", "Current weight of the choice for smooth weighted round-robin.", "gauge",
+		func(_ *balancer.Stats, c *balancer.ChoiceStats) float64 { return float64(c.CurrentWeight) }},
+	{"balancer_choice_inflight", "Number of requests in flight.", "gauge",
+		func(_ *balancer.Stats, c *balancer.ChoiceStats) float64 { return float64(c.Inflight) }},
+	{"balancer_choice_limit", "Concurrency limit of the choice, 0 is unlimited.", "gauge",
+		func(_ *balancer.Stats, c *balancer.ChoiceStats) float64 { return float64(c.Limit) }},
+	{"balancer_choice_ejected", "Whether the choice is ejected.", "gauge",
+		func(_ *balancer.Stats, c *balancer.ChoiceStats) float64 { return boolValue(c.Ejected) }},
+	{"balancer_choice_available", "Whether the choice can be selected.", "gauge",
+		func(_ *balancer.Stats, c *balancer.ChoiceStats) float64 { return boolValue(c.Available) }},
+}
+
+const latencyFamily = "balancer_selection_duration_seconds"
+
+// WriteText writes the stats in the Prometheus text exposition format.
+func WriteText(w io.Writer, stats ...balancer.Stats) error {
+	bw := bufio.NewWriter(w)
+
+	for _, f := range balancerFamilies {
+		writeHeader(bw, f.name, f.help, f.typ)
+		for i := range stats {
+			s := &stats[i]
+			writeSample(bw, f.name, f.value(s, nil), "balancer", s.Name)
+		}
+	}
+
+	writeHeader(bw, latencyFamily, "Latency of the selections.", "histogram")
+	for i := range stats {
+		s := &stats[i]
+		var n uint64
+		for j, b := range s.Latency.Bounds {
+			n += s.Latency.Counts[j]
+			writeSample(bw, latencyFamily+"_bucket", float64(n), "balancer", s.Name, "le", formatFloat(b.Seconds()))
+		}
+		writeSample(bw, latencyFamily+"_bucket", float64(s.Latency.Count), "balancer", s.Name, "le", "+Inf")
+		writeSample(bw, latencyFamily+"_sum", s.Latency.Sum.Seconds(), "balancer", s.Name)
+		writeSample(bw, latencyFamily+"_count", float64(s.Latency.Count), "balancer", s.Name)
+	}
+
+	for _, f := range choiceFamilies {
+		writeHeader(bw, f.name, f.help, f.typ)
+		for i := range stats {
+			s := &stats[i]
+			for j := range s.Choices {
+				c := &s.Choices[j]
+				writeSample(bw, f.name, f.value(s, c), "balancer", s.Name, "item", c.Key)
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	w.WriteString("# HELP ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(escape(help, false))
+	w.WriteString("\n# TYPE ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(typ)
+	w.WriteByte('\n')
+}
+
+// writeSample writes one sample, labels are name / value pairs.
+func writeSample(w *bufio.Writer, name string, v float64, labels ...string) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(labels[i])
+			w.WriteString(`="`)
+			w.WriteString(escape(labels[i+1], true))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// escape escapes a HELP text, or a label value with the double quote as well.
+func escape(s string, label bool) string {
+	if label {
+		return labelEscaper.Replace(s)
+	}
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	balancer "github.com/shibingli/load-balancer"
+)
+
+func TestHandler(t *testing.T) {
+	choices := []*balancer.Choice{
+		{Item: "10.0.0.1:80", Weight: 3},
+		{Item: "a\"b\\c\nd", Weight: 1},
+		{Item: []string{"10.0.0.2:80"}, ID: "shard-2", Weight: 0},
+	}
+	c := balancer.NewCluster(balancer.NewWeightedRoundRobin(), choices...)
+	for i := 0; i < 4; i++ {
+		c.Select()
+	}
+	c.Eject(choices[1], "test")
+
+	h := NewHandler()
+	h.Register(c)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rr.Header().Get("Content-Type"); ct != ContentType {
+		t.Fatalf("content type wrong: %s", ct)
+	}
+	body := rr.Body.String()
+	for _, line := range []string{
+		"# TYPE balancer_selections_total counter",
+		`balancer_selections_total{balancer="WeightedRoundRobin"} 4`,
+		`balancer_selection_errors_total{balancer="WeightedRoundRobin"} 0`,
+		"# TYPE balancer_selection_duration_seconds histogram",
+		`balancer_selection_duration_seconds_bucket{balancer="WeightedRoundRobin",le="5e-08"} `,
+		`balancer_selection_duration_seconds_bucket{balancer="WeightedRoundRobin",le="+Inf"} 4`,
+		`balancer_selection_duration_seconds_count{balancer="WeightedRoundRobin"} 4`,
+		`balancer_choice_selections_total{balancer="WeightedRoundRobin",item="10.0.0.1:80"} 3`,
+		`balancer_choice_selections_total{balancer="WeightedRoundRobin",item="a\"b\\c\nd"} 1`,
+		`balancer_choice_weight{balancer="WeightedRoundRobin",item="10.0.0.1:80"} 3`,
+		`balancer_choice_effective_weight{balancer="WeightedRoundRobin",item="a\"b\\c\nd"} 0`,
+		`balancer_choice_ejected{balancer="WeightedRoundRobin",item="a\"b\\c\nd"} 1`,
+		`balancer_choice_available{balancer="WeightedRoundRobin",item="10.0.0.1:80"} 1`,
+		`balancer_choice_weight{balancer="WeightedRoundRobin",item="shard-2"} 0`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatalf("metrics expected %q in:\n%s", line, body)
+		}
+	}
+
+	// every family has a single header, and samples of a family are not interleaved
+	seen := make(map[string]bool)
+	last := ""
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			name := strings.Fields(line)[2]
+			if seen[name] {
+				t.Fatalf("metrics family repeated: %s", name)
+			}
+			seen[name] = true
+			last = name
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, last) {
+			t.Fatalf("metrics sample out of family %s: %s", last, line)
+		}
+	}
+
+	h.Unregister(c)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(rr.Body.String(), "WeightedRoundRobin") {
+		t.Fatal("metrics unregister wrong")
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if v := escape("a\\b\n\"c\"", true); v != `a\\b\n\"c\"` {
+		t.Fatalf("escape label wrong: %s", v)
+	}
+	if v := escape("a\\b\n\"c\"", false); v != `a\\b\n"c"` {
+		t.Fatalf("escape help wrong: %s", v)
+	}
+}
@@ -0,0 +1,256 @@
+package balancer
+
+import (
+	"sync"
+
+	"github.com/shibingli/load-balancer/utils"
+)
+
+// Migration holds the old and the new choices of a hash balancer during a resharding:
+// each key gets its new owner and its previous one, so that the clients can read through
+// from the previous owner while the data of the key moves. It is a Balancer selecting the new owners,
+// and is goroutine-safe.
+//
+//	m := balancer.NewMigration(lb, balancer.NewConsistentHash(next...))
+//	owner, previous, _ := m.OwnerChoices(key)
+//	if v, ok := get(owner.Item, key); !ok && owner.Key() != previous.Key() {
+//		v, ok = get(previous.Item, key)
+//		// ... copy v to owner
+//		m.Migrated(key)
+//	}
+//	// once the moved keys are copied or expired
+//	lb = m.Finalize()
+type Migration struct {
+	mu        sync.Mutex
+	from      Balancer
+	to        Balancer
+	finalized bool
+
+	selections uint64
+	// the hashes of the moved keys sampled, true once migrated
+	moved map[uint64]bool
+	// the keys of the hashes below 2^(64-shift) are sampled
+	shift     uint
+	movedKeys uint64
+	migrated  uint64
+}
+
+// MigrationSamples is the most moved keys a Migration tracks,
+// beyond it the keys are sampled and the counts of its Progress are estimates.
+var MigrationSamples = 1 << 16
+
+// MigrationProgress is a snapshot of the keys of a Migration.
+type MigrationProgress struct {
+	// Selections is the number of selections since the start of the migration.
+	Selections uint64
+
+	// Moved is the number of distinct keys selected or migrated whose owner changed,
+	// estimated beyond MigrationSamples keys.
+	Moved uint64
+
+	// Migrated is the number of the moved keys reported by Migrated.
+	Migrated uint64
+
+	Finalized bool
+}
+
+// Done returns the fraction of the moved keys reported as migrated, 1 without moved keys.
+func (p MigrationProgress) Done() float64 {
+	if p.Moved == 0 {
+		return 1
+	}
+	return float64(p.Migrated) / float64(p.Moved)
+}
+
+// NewMigration starts a migration from the balancer from to the balancer to,
+// usually of the same mode with the new choices.
+func NewMigration(from, to Balancer) *Migration {
+	return &Migration{
+		from:  from,
+		to:    to,
+		moved: make(map[uint64]bool),
+	}
+}
+
+// Owners gets the new owner of the key and its previous owner,
+// which are the same for the keys that do not move and once finalized.
+// Items that are not comparable, e.g. slices, are told apart by the Key of OwnerChoices.
+func (m *Migration) Owners(key ...string) (owner, previous interface{}, err error) {
+	oc, pc, err := m.OwnerChoices(key...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oc.Item, pc.Item, nil
+}
+
+// OwnerChoices is like Owners, but returns the *Choice of the owners.
+// If the previous owner is not available, it is the new owner.
+func (m *Migration) OwnerChoices(key ...string) (owner, previous *Choice, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owner, previous, err = m.owners(key)
+	if err != nil || m.finalized {
+		return
+	}
+	m.selections++
+	if owner != previous {
+		m.track(key, false)
+	}
+	return
+}
+
+// keyOwner is implemented by the hash balancers, to get the owner of a key regardless of its availability.
+type keyOwner interface {
+	keyOwner(key []string) *Choice
+}
+
+func (m *Migration) owners(key []string) (owner, previous *Choice, err error) {
+	owner, err = selectChoice(m.to, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m.finalized {
+		return owner, owner, nil
+	}
+	if o, ok := m.from.(keyOwner); ok {
+		previous = o.keyOwner(key)
+	} else {
+		previous, _ = selectChoice(m.from, key)
+	}
+	if previous == nil || !previous.Available() || sameChoice(owner, previous) {
+		return owner, owner, nil
+	}
+	return owner, previous, nil
+}
+
+// track records a moved key if it is sampled, halving the sample when it is full.
+func (m *Migration) track(key []string, migrated bool) {
+	h := mix64(utils.HashString(key...))
+	if !m.sampled(h) {
+		return
+	}
+	done, ok := m.moved[h]
+	if !ok {
+		m.movedKeys++
+	}
+	if migrated && !done {
+		m.migrated++
+	}
+	m.moved[h] = done || migrated
+
+	for len(m.moved) > migrationSamples() {
+		m.shift++
+		for h, done := range m.moved {
+			if !m.sampled(h) {
+				delete(m.moved, h)
+				m.movedKeys--
+				if done {
+					m.migrated--
+				}
+			}
+		}
+	}
+}
+
+func (m *Migration) sampled(hash uint64) bool {
+	return m.shift == 0 || hash>>(64-m.shift) == 0
+}
+
+func migrationSamples() int {
+	if MigrationSamples <= 0 {
+		return 1 << 16
+	}
+	return MigrationSamples
+}
+
+// Migrated reports that the data of the key has been moved to its new owner,
+// e.g. by a read-through or a background copy. Keys that do not move are ignored.
+func (m *Migration) Migrated(key ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owner, previous, err := m.owners(key)
+	if err != nil || owner == previous {
+		return
+	}
+	m.track(key, true)
+}
+
+// Progress returns a snapshot of the keys selected and migrated.
+func (m *Migration) Progress() MigrationProgress {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return MigrationProgress{
+		Selections: m.selections,
+		Moved:      m.movedKeys << m.shift,
+		Migrated:   m.migrated << m.shift,
+		Finalized:  m.finalized,
+	}
+}
+
+// Finalize ends the migration and returns the new balancer,
+// the previous owners are no longer returned.
+func (m *Migration) Finalize() Balancer {
+	m.mu.Lock()
+	m.finalized = true
+	m.moved = nil
+	m.mu.Unlock()
+	return m.to
+}
+
+func (m *Migration) Select(key ...string) (item interface{}) {
+	item, _ = m.SelectE(key...)
+	return
+}
+
+func (m *Migration) SelectE(key ...string) (interface{}, error) {
+	c, err := m.SelectChoice(key...)
+	if err != nil {
+		return nil, err
+	}
+	return c.Item, nil
+}
+
+// SelectChoice selects the new owner of the key.
+func (m *Migration) SelectChoice(key ...string) (*Choice, error) {
+	c, _, err := m.OwnerChoices(key...)
+	return c, err
+}
+
+// SelectN selects with the new choices.
+func (m *Migration) SelectN(n int, key ...string) []interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return selectN(m.to, n, key)
+}
+
+// Choices returns the new choices, if the new balancer is a ChoiceLister.
+func (m *Migration) Choices() []*Choice {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.to.(ChoiceLister); ok {
+		return l.Choices()
+	}
+	return nil
+}
+
+func (m *Migration) Name() string {
+	return m.to.Name()
+}
+
+// Update replaces the new choices, the previous owners stay the ones of the old choices.
+func (m *Migration) Update(choices []*Choice) bool {
+	return m.UpdateE(choices) == nil
+}
+
+func (m *Migration) UpdateE(choices []*Choice) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return updateE(m.to, choices)
+}
+
+// sameChoice reports whether a and b are the same choice, or choices of the same Key.
+func sameChoice(a, b *Choice) bool {
+	return a == b || a.Key() == b.Key()
+}
@@ -0,0 +1,157 @@
+package balancer
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestMigration(t *testing.T) {
+	old := NewChoicesSlice([]string{"A", "B", "C", "D"})
+	next := NewChoicesSlice([]string{"A", "B", "C", "D", "E"})
+	m := NewMigration(NewConsistentHash(old...), NewConsistentHash(next...))
+	if m.Name() != "ConsistentHash" {
+		t.Fatal("migration name wrong")
+	}
+
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		owner, previous, err := m.Owners(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owner != NewConsistentHash(next...).Select(key) || previous != NewConsistentHash(old...).Select(key) {
+			t.Fatal("migration owners wrong")
+		}
+		if owner != previous {
+			// equal items of distinct choices are the same owner
+			if owner != "E" {
+				t.Fatalf("migration moved a key from %v to %v", previous, owner)
+			}
+			moved++
+		}
+		if m.Select(key) != owner {
+			t.Fatal("migration select wrong")
+		}
+	}
+	if moved < 100 || moved > 300 {
+		t.Fatalf("migration moved %d keys", moved)
+	}
+
+	p := m.Progress()
+	if p.Selections != 2000 || p.Moved != uint64(moved) || p.Migrated != 0 || p.Done() != 0 {
+		t.Fatalf("migration progress wrong: %+v", p)
+	}
+	for i := 0; i < 1000; i++ {
+		m.Migrated(strconv.Itoa(i))
+		m.Migrated(strconv.Itoa(i))
+	}
+	if p = m.Progress(); p.Migrated != uint64(moved) || p.Done() != 1 {
+		t.Fatalf("migration progress wrong: %+v", p)
+	}
+
+	if m.Choices()[4].Item != "E" {
+		t.Fatal("migration choices wrong")
+	}
+	lb := m.Finalize()
+	if lb.(ChoiceLister).Choices()[4].Item != "E" {
+		t.Fatal("migration expected the new balancer")
+	}
+	for i := 0; i < 1000; i++ {
+		owner, previous, _ := m.Owners(strconv.Itoa(i))
+		if owner != previous {
+			t.Fatal("migration expected no previous owner once finalized")
+		}
+	}
+	if p = m.Progress(); !p.Finalized || p.Selections != 2000 || p.Moved != uint64(moved) {
+		t.Fatalf("migration progress wrong: %+v", p)
+	}
+}
+
+func TestMigration_Unavailable(t *testing.T) {
+	old := NewChoicesSlice([]string{"A", "B"})
+	m := NewMigration(NewConsistentHash(old...), NewConsistentHash(NewChoicesSlice([]string{"C"})...))
+	old[0].ejected, old[1].ejected = 1, 1
+	owner, previous, err := m.Owners("k")
+	if err != nil || owner != "C" || previous != "C" {
+		t.Fatalf("migration expected the new owner, actual %v %v %v", owner, previous, err)
+	}
+
+	m = NewMigration(NewConsistentHash(old...), NewConsistentHash())
+	if _, _, err := m.Owners("k"); err != ErrNoChoices {
+		t.Fatal("migration expected ErrNoChoices")
+	}
+	if _, err := m.SelectE(); err != ErrNoChoices {
+		t.Fatal("migration expected ErrNoChoices")
+	}
+	if !m.Update(NewChoicesSlice([]string{"D"})) || m.Select("k") != "D" || len(m.SelectN(2, "k")) != 1 {
+		t.Fatal("migration update wrong")
+	}
+}
+
+func TestMigration_PreviousUnavailable(t *testing.T) {
+	old := NewChoicesSlice([]string{"A", "B", "C", "D"})
+	from, to := NewConsistentHash(old...), NewConsistentHash(NewChoicesSlice([]string{"A", "B", "C", "D", "E"})...)
+	m := NewMigration(from, to)
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		owner, previous, _ := m.Owners(key)
+		if owner != "E" || previous != "A" {
+			continue
+		}
+		// the fallback of the old balancer is not the previous owner
+		old[0].ejected = 1
+		if owner, previous, _ = m.Owners(key); owner != "E" || previous != "E" {
+			t.Fatalf("migration expected the new owner as the previous one, actual %v", previous)
+		}
+		old[0].ejected = 0
+		return
+	}
+	t.Fatal("migration expected a key moving from A to E")
+}
+
+func TestMigration_Samples(t *testing.T) {
+	defer func(n int) { MigrationSamples = n }(MigrationSamples)
+	MigrationSamples = 100
+
+	m := NewMigration(NewAnchorHash(genNodes(10)...), NewAnchorHash(genNodes(20)...))
+	moved := 0
+	for i := 0; i < 20000; i++ {
+		key := strconv.Itoa(i)
+		if owner, previous, _ := m.Owners(key); owner != previous {
+			moved++
+			if i%2 == 0 {
+				m.Migrated(key)
+			}
+		}
+	}
+	if len(m.moved) > 100 {
+		t.Fatalf("migration tracked %d keys", len(m.moved))
+	}
+	p := m.Progress()
+	if p.Moved < uint64(moved)/2 || p.Moved > uint64(moved)*2 || p.Done() < 0.3 || p.Done() > 0.7 {
+		t.Fatalf("migration progress of %d moved keys wrong: %+v", moved, p)
+	}
+}
+
+func TestMigration_Concurrent(t *testing.T) {
+	m := NewMigration(NewAnchorHash(genNodes(10)...), NewAnchorHash(genNodes(12)...))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := strconv.Itoa(i)
+				if owner, previous, _ := m.Owners(key); owner != previous {
+					m.Migrated(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if p := m.Progress(); p.Selections != 8000 || p.Moved == 0 || p.Done() != 1 {
+		t.Fatalf("migration progress wrong: %+v", p)
+	}
+}
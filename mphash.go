@@ -0,0 +1,208 @@
+package balancer
+
+import (
+	"sort"
+
+	"github.com/shibingli/load-balancer/hasher"
+)
+
+// DefaultProbes is the number of probes of MultiProbeConsistentHash,
+// about 1.05 peak-to-mean load ratio according to the paper.
+const DefaultProbes = 21
+
+// MultiProbeConsistentHash places each item once on a hash ring and hashes the key k times,
+// the item closest after any of the probes wins. More probes balance better and cost more per lookup,
+// the memory is one entry per item, unlike the virtual nodes of a ring hash.
+// Ref: https://arxiv.org/abs/1505.00062
+type mpHash struct {
+	ring   []ringNode
+	probes int
+	hashBalancer
+}
+
+type ringNode struct {
+	hash   uint64
+	choice *Choice
+}
+
+func NewMultiProbeConsistentHash(choices ...*Choice) (lb *mpHash) {
+	return NewMultiProbeConsistentHashWithProbes(DefaultProbes, choices...)
+}
+
+// NewMultiProbeConsistentHashWithProbes create a MultiProbeConsistentHash balancer hashing the key probes times.
+func NewMultiProbeConsistentHashWithProbes(probes int, choices ...*Choice) (lb *mpHash) {
+	if probes < 1 {
+		probes = 1
+	}
+	lb = &mpHash{probes: probes}
+	lb.placement = lb
+	lb.Update(choices)
+	return
+}
+
+// chooseN takes the next closest items after the probes.
+func (b *mpHash) chooseN(n int, hash uint64) []*Choice {
+	if want := countAvailable(b.items); n > want {
+		n = want
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	probes := make([]uint64, b.probes)
+	cursors := make([]int, b.probes)
+	for i := range probes {
+		probes[i] = b.probe(hash, i)
+		cursors[i] = b.successor(probes[i])
+	}
+
+	choices := make([]*Choice, 0, n)
+	seen := make(map[*Choice]struct{}, n)
+	// steps of each cursor, at most once around the ring: the availability may change meanwhile
+	steps := make([]int, b.probes)
+	for len(choices) < n {
+		best, min := -1, uint64(0)
+		for i := range probes {
+			// move past the items taken or not available
+			for steps[i] < len(b.ring) {
+				c := b.ring[cursors[i]].choice
+				if _, ok := seen[c]; !ok && c.Available() {
+					break
+				}
+				cursors[i] = (cursors[i] + 1) % len(b.ring)
+				steps[i]++
+			}
+			if steps[i] == len(b.ring) {
+				continue
+			}
+			if d := b.ring[cursors[i]].hash - probes[i]; best < 0 || d < min {
+				best, min = i, d
+			}
+		}
+		if best < 0 {
+			break
+		}
+		c := b.ring[cursors[best]].choice
+		seen[c] = struct{}{}
+		choices = append(choices, c)
+	}
+	return choices
+}
+
+// choose returns the available item closest after any of the probes.
+func (b *mpHash) choose(hash uint64) (*Choice, error) {
+	var (
+		choice *Choice
+		min    uint64
+	)
+	for i := 0; i < b.probes; i++ {
+		p := b.probe(hash, i)
+		j := b.successor(p)
+		for k := 0; k < len(b.ring); k++ {
+			node := &b.ring[(j+k)%len(b.ring)]
+			if !node.choice.Available() {
+				continue
+			}
+			if d := node.hash - p; choice == nil || d < min {
+				choice, min = node.choice, d
+			}
+			break
+		}
+		if choice == nil {
+			// no probe will find one
+			return nil, unavailable(b.items)
+		}
+	}
+	return choice, nil
+}
+
+// probe returns the i-th probe of the key by double hashing.
+func (b *mpHash) probe(hash uint64, i int) uint64 {
+	h1 := mix64(hash)
+	h2 := mix64(h1) | 1
+	return h1 + uint64(i)*h2
+}
+
+// successor returns the index of the first node at or after the hash, wrapping around.
+func (b *mpHash) successor(hash uint64) int {
+	i := sort.Search(len(b.ring), func(i int) bool {
+		return b.ring[i].hash >= hash
+	})
+	if i == len(b.ring) {
+		return 0
+	}
+	return i
+}
+
+// SetHasher replaces the hasher of the keys and of the items on the ring.
+// Like Update, it must not be called concurrently with a selection.
+func (b *mpHash) SetHasher(h hasher.Hasher) {
+	b.hasher = h
+	b.Update(b.items)
+}
+
+// Ownership estimates the ownership of the choices, see Analyzer.
+func (b *mpHash) Ownership() Ownership {
+	return sampleOwnership(b.items, b.owner)
+}
+
+// Remap estimates the keys moving by an Update with the choices, see Analyzer.
+func (b *mpHash) Remap(choices []*Choice) Remap {
+	next := NewMultiProbeConsistentHashWithProbes(b.probes)
+	next.hasher = b.hasher
+	_ = next.UpdateE(choices)
+	return sampleRemap(b.owner, next.owner)
+}
+
+// owner returns the closest item to the probes, available or not.
+func (b *mpHash) owner(hash uint64) *Choice {
+	if b.count == 0 {
+		return nil
+	}
+	var (
+		choice *Choice
+		min    uint64
+	)
+	for i := 0; i < b.probes; i++ {
+		p := b.probe(hash, i)
+		node := &b.ring[b.successor(p)]
+		if d := node.hash - p; choice == nil || d < min {
+			choice, min = node.choice, d
+		}
+	}
+	return choice
+}
+
+func (b *mpHash) Name() string {
+	return "MultiProbeConsistentHash"
+}
+
+func (b *mpHash) Update(choices []*Choice) bool {
+	return b.UpdateE(choices) == nil
+}
+
+func (b *mpHash) UpdateE(choices []*Choice) error {
+	b.items, b.count = cleanChoices(choices)
+	b.labels = &labelCounts{}
+	b.ring = make([]ringNode, b.count)
+	for i, c := range b.items {
+		b.ring[i] = ringNode{hash: mix64(b.hashKey([]string{c.Key()})), choice: c}
+	}
+	sort.Slice(b.ring, func(i, j int) bool {
+		return b.ring[i].hash < b.ring[j].hash
+	})
+	if b.count == 0 {
+		return ErrNoChoices
+	}
+	return nil
+}
+
+// mix64 is the finalizer of MurmurHash3, it spreads similar hashes over the ring.
+func mix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
@@ -0,0 +1,170 @@
+package balancer
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shibingli/load-balancer/hasher"
+)
+
+func genNodes(n int) []*Choice {
+	choices := make([]*Choice, n)
+	for i := range choices {
+		choices[i] = &Choice{Item: "10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)}
+	}
+	return choices
+}
+
+// peakToMean returns the load of the busiest item relative to the average.
+func peakToMean(lb Balancer, keys, items int) float64 {
+	count := make(map[interface{}]int)
+	max := 0
+	for i := 0; i < keys; i++ {
+		item := lb.Select("key-" + strconv.Itoa(i))
+		count[item]++
+		if count[item] > max {
+			max = count[item]
+		}
+	}
+	return float64(max) / (float64(keys) / float64(items))
+}
+
+func TestMultiProbeConsistentHash(t *testing.T) {
+	lb := NewMultiProbeConsistentHash()
+	if item := lb.Select("a"); item != nil {
+		t.Fatalf("mphash expected nil, actual %s", item)
+	}
+	if _, err := lb.SelectE("a"); err != ErrNoChoices {
+		t.Fatalf("mphash expected ErrNoChoices, actual %v", err)
+	}
+
+	lb = NewMultiProbeConsistentHash(NewChoicesSlice([]string{"A", "B", "C", "D"})...)
+	if _, err := lb.SelectE(); err != ErrKeyRequired {
+		t.Fatalf("mphash expected ErrKeyRequired, actual %v", err)
+	}
+	count := make(map[interface{}]int)
+	for i := 0; i < 1000; i++ {
+		key := "192.168.1." + strconv.Itoa(i)
+		item := lb.Select(key)
+		if lb.Select(key) != item || lb.SelectBytes([]byte(key)) != item {
+			t.Fatal("mphash expected the same item")
+		}
+		count[item]++
+	}
+	if len(count) != 4 {
+		t.Fatalf("mphash wrong: %v", count)
+	}
+
+	lb.Update(NewChoicesSlice([]string{"X"}))
+	if lb.Select("a") != "X" || len(lb.Choices()) != 1 {
+		t.Fatal("mphash update wrong")
+	}
+}
+
+func TestMultiProbeConsistentHash_Balance(t *testing.T) {
+	const n, keys = 100, 200000
+	choices := genNodes(n)
+
+	one := peakToMean(NewMultiProbeConsistentHashWithProbes(1, choices...), keys, n)
+	many := peakToMean(NewMultiProbeConsistentHash(choices...), keys, n)
+	if many > 1.2 || many >= one {
+		t.Fatalf("mphash peak-to-mean wrong: 1 probe %.2f, %d probes %.2f", one, DefaultProbes, many)
+	}
+
+	// the hasher changes the placement, not the balance
+	lb := NewMultiProbeConsistentHash(choices...)
+	lb.SetHasher(hasher.XXHash64{})
+	if r := peakToMean(lb, keys, n); r > 1.2 {
+		t.Fatalf("mphash peak-to-mean wrong with xxhash: %.2f", r)
+	}
+}
+
+func TestMultiProbeConsistentHash_Remap(t *testing.T) {
+	const keys = 20000
+	choices := genNodes(50)
+	lb := NewMultiProbeConsistentHash(choices...)
+	owners := make([]interface{}, keys)
+	for i := range owners {
+		owners[i] = lb.Select("key-" + strconv.Itoa(i))
+	}
+
+	// removing an item only moves its own keys
+	removed := choices[7].Item
+	lb.Update(append(append([]*Choice{}, choices[:7]...), choices[8:]...))
+	for i := range owners {
+		if item := lb.Select("key-" + strconv.Itoa(i)); owners[i] != removed && item != owners[i] {
+			t.Fatalf("mphash moved a key of %v to %v", owners[i], item)
+		}
+	}
+
+	// adding an item only moves keys to it
+	added := &Choice{Item: "10.1.0.1"}
+	lb.Update(append(choices, added))
+	moved := 0
+	for i := range owners {
+		if item := lb.Select("key-" + strconv.Itoa(i)); item != owners[i] {
+			if item != added.Item {
+				t.Fatalf("mphash moved a key of %v to %v", owners[i], item)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > keys/51*2 {
+		t.Fatalf("mphash moved %d keys", moved)
+	}
+}
+
+func TestMultiProbeConsistentHash_Skip(t *testing.T) {
+	choices := genNodes(10)
+	lb := NewMultiProbeConsistentHash(choices...)
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		items := lb.SelectN(3, key)
+		if items[0] != lb.Select(key) {
+			t.Fatal("mphash expected the owner first")
+		}
+
+		// the fallback of an ejected owner is the next replica
+		var owner *Choice
+		for _, c := range choices {
+			if c.Item == items[0] {
+				owner = c
+			}
+		}
+		owner.ejected = 1
+		if item := lb.Select(key); item != items[1] {
+			t.Fatalf("mphash expected %v, actual %v", items[1], item)
+		}
+		owner.ejected = 0
+	}
+}
+
+func TestMultiProbeConsistentHash_Ejecting(t *testing.T) {
+	choices := genNodes(10)
+	lb := NewMultiProbeConsistentHash(choices...)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			// every item ejected for a while, e.g. by the health checks of a Cluster
+			for _, c := range choices {
+				atomic.StoreInt32(&c.ejected, int32(i%2))
+			}
+		}
+	}()
+	// the walks are bounded when the items are ejected during a selection
+	for i := 0; i < 10000; i++ {
+		if items := lb.SelectN(5, strconv.Itoa(i)); len(items) > 5 {
+			t.Fatalf("mphash expected at most 5 items, actual %v", items)
+		}
+	}
+	close(stop)
+	<-done
+}
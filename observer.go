@@ -0,0 +1,128 @@
+package balancer
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Observer is notified of the events of a Cluster, see Cluster.Observe.
+// Callbacks run synchronously on the goroutine that caused the event and must not block.
+type Observer interface {
+	// OnSelect is called after an item has been selected, key is nil for non-hash balancers.
+	OnSelect(item interface{}, key []string)
+
+	// OnUpdate is called after the choices have been replaced.
+	OnUpdate(old, new []*Choice)
+
+	// OnEject is called when an item is ejected.
+	OnEject(item interface{}, reason string)
+
+	// OnRecover is called when an ejected item recovers.
+	OnRecover(item interface{})
+}
+
+// ObserverFuncs is an Observer made of optional functions.
+type ObserverFuncs struct {
+	Select  func(item interface{}, key []string)
+	Update  func(old, new []*Choice)
+	Eject   func(item interface{}, reason string)
+	Recover func(item interface{})
+}
+
+func (f *ObserverFuncs) OnSelect(item interface{}, key []string) {
+	if f.Select != nil {
+		f.Select(item, key)
+	}
+}
+
+func (f *ObserverFuncs) OnUpdate(old, new []*Choice) {
+	if f.Update != nil {
+		f.Update(old, new)
+	}
+}
+
+func (f *ObserverFuncs) OnEject(item interface{}, reason string) {
+	if f.Eject != nil {
+		f.Eject(item, reason)
+	}
+}
+
+func (f *ObserverFuncs) OnRecover(item interface{}) {
+	if f.Recover != nil {
+		f.Recover(item)
+	}
+}
+
+// observers is a copy-on-write list of observers, notifying does not take a lock.
+type observers struct {
+	mu   sync.Mutex
+	next uint64
+	list atomic.Value // []observerEntry
+}
+
+type observerEntry struct {
+	id uint64
+	o  Observer
+}
+
+func (s *observers) add(o Observer) (remove func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next++
+	id := s.next
+	old := s.load()
+	list := make([]observerEntry, len(old), len(old)+1)
+	copy(list, old)
+	s.list.Store(append(list, observerEntry{id: id, o: o}))
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.remove(id)
+		})
+	}
+}
+
+func (s *observers) remove(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.load()
+	list := make([]observerEntry, 0, len(old))
+	for _, e := range old {
+		if e.id != id {
+			list = append(list, e)
+		}
+	}
+	s.list.Store(list)
+}
+
+func (s *observers) load() []observerEntry {
+	list, _ := s.list.Load().([]observerEntry)
+	return list
+}
+
+func (s *observers) onSelect(item interface{}, key []string) {
+	for _, e := range s.load() {
+		e.o.OnSelect(item, key)
+	}
+}
+
+func (s *observers) onUpdate(old, new []*Choice) {
+	for _, e := range s.load() {
+		e.o.OnUpdate(old, new)
+	}
+}
+
+func (s *observers) onEject(item interface{}, reason string) {
+	for _, e := range s.load() {
+		e.o.OnEject(item, reason)
+	}
+}
+
+func (s *observers) onRecover(item interface{}) {
+	for _, e := range s.load() {
+		e.o.OnRecover(item)
+	}
+}
@@ -0,0 +1,136 @@
+package balancer
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(e string) {
+	r.mu.Lock()
+	r.events = append(r.events, e)
+	r.mu.Unlock()
+}
+
+func (r *recorder) OnSelect(item interface{}, key []string) {
+	if len(key) > 0 {
+		r.add("select " + item.(string) + " " + key[0])
+		return
+	}
+	r.add("select " + item.(string))
+}
+
+func (r *recorder) OnUpdate(old, new []*Choice) {
+	r.add("update " + strconv.Itoa(len(old)) + " " + strconv.Itoa(len(new)))
+}
+
+func (r *recorder) OnEject(item interface{}, reason string) {
+	r.add("eject " + item.(string) + " " + reason)
+}
+
+func (r *recorder) OnRecover(item interface{}) {
+	r.add("recover " + item.(string))
+}
+
+func TestObserver(t *testing.T) {
+	choices := NewChoicesSlice([]string{"A", "B"})
+	c := NewCluster(NewRoundRobin(), choices...)
+
+	r := &recorder{}
+	remove := c.Observe(r)
+	var n int64
+	removeFuncs := c.Observe(&ObserverFuncs{
+		Select: func(interface{}, []string) { atomic.AddInt64(&n, 1) },
+	})
+
+	c.Select()
+	l, err := c.SelectContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Done(nil)
+	c.Eject(choices[0], "5xx")
+	c.Eject(choices[0], "5xx")
+	c.Recover(choices[0])
+	c.Recover(choices[0])
+	c.Update(choices[:1])
+
+	h := NewCluster(NewConsistentHash(), choices...)
+	h.Observe(r)
+	h.Select("192.168.1.1")
+
+	removeFuncs()
+	c.Select()
+	remove()
+	remove()
+	c.Select()
+
+	expected := []string{
+		"select A",
+		"select B",
+		"eject A 5xx",
+		"recover A",
+		"update 2 1",
+		"select B 192.168.1.1",
+		"select A",
+	}
+	if len(r.events) != len(expected) {
+		t.Fatalf("observer expected %v, actual %v", expected, r.events)
+	}
+	for i := range expected {
+		if r.events[i] != expected[i] {
+			t.Fatalf("observer expected %v, actual %v", expected, r.events)
+		}
+	}
+	if atomic.LoadInt64(&n) != 2 {
+		t.Fatalf("observer funcs expected 2, actual %d", n)
+	}
+}
+
+func TestObserver_C(t *testing.T) {
+	c := NewCluster(NewRandom(), NewChoicesSlice([]string{"A", "B", "C"})...)
+
+	var (
+		wg    sync.WaitGroup
+		a, b  int64
+		stop  = make(chan struct{})
+		added = make(chan struct{})
+	)
+	c.Observe(&ObserverFuncs{Select: func(interface{}, []string) { atomic.AddInt64(&a, 1) }})
+
+	// observers come and go while selecting
+	go func() {
+		defer close(added)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				c.Observe(&ObserverFuncs{Select: func(interface{}, []string) { atomic.AddInt64(&b, 1) }})()
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				c.Select()
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-added
+
+	if atomic.LoadInt64(&a) != 10000 {
+		t.Fatalf("observer expected 10000, actual %d", a)
+	}
+}
@@ -0,0 +1,284 @@
+// Package placement places replicas of keys on a hierarchy of failure domains, e.g.
+// region → rack → host → disk, like the CRUSH algorithm of Ceph with straw2 buckets.
+//
+// The items are balancer choices whose Labels hold their topology path and whose Weight
+// is their capacity. Each key is mapped to an ordered list of replicas: the same key gets
+// the same replicas, and changing the weight of an item or a bucket only moves replicas
+// to or from it.
+// Ref: https://ceph.io/assets/pdfs/weil-crush-sc06.pdf
+package placement
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	balancer "github.com/shibingli/load-balancer"
+	"github.com/shibingli/load-balancer/utils"
+)
+
+var (
+	// ErrMissingLabel is returned for a choice without a label of the hierarchy.
+	ErrMissingLabel = errors.New("placement: missing topology label")
+
+	// ErrUnknownLevel is returned for a rule or a bucket path of a level not in the hierarchy.
+	ErrUnknownLevel = errors.New("placement: unknown level")
+
+	// ErrUnknownBucket is returned for a bucket path not in the hierarchy.
+	ErrUnknownBucket = errors.New("placement: unknown bucket")
+)
+
+// maxTries bounds the retries of a replica after collisions or unavailable items.
+const maxTries = 50
+
+// Map is a hierarchy of buckets with the choices as leaves.
+// It is safe for concurrent use, but not concurrently with SetWeight.
+type Map struct {
+	levels []string
+	root   *bucket
+}
+
+type bucket struct {
+	name     string
+	id       uint64
+	level    int
+	weight   int
+	fixed    bool // weight set by SetWeight instead of the sum of the children
+	children []*bucket
+	choice   *balancer.Choice
+}
+
+// Rule places Replicas replicas, each in a distinct bucket of the FailureDomain level,
+// e.g. Rule{Replicas: 3, FailureDomain: "rack"}. An empty FailureDomain only requires
+// distinct choices.
+type Rule struct {
+	Replicas      int
+	FailureDomain string
+}
+
+// New builds the hierarchy of the levels from the labels of the choices, from the top, e.g.
+//
+//	m, err := placement.New([]string{"region", "rack", "host"}, disks)
+//
+// where every disk has the labels "region", "rack" and "host". The weight of a bucket is
+// the sum of the weights of its children, see SetWeight.
+func New(levels []string, choices []*balancer.Choice) (*Map, error) {
+	m := &Map{
+		levels: append([]string(nil), levels...),
+		root:   &bucket{level: -1, id: utils.Sum64("")},
+	}
+	for _, c := range choices {
+		if c == nil {
+			continue
+		}
+		b := m.root
+		path := ""
+		for i, level := range levels {
+			name, ok := c.Labels[level]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q of %v", ErrMissingLabel, level, c.Item)
+			}
+			path += level + "=" + name + "/"
+			b = b.child(name, path, i)
+		}
+		b.children = append(b.children, &bucket{
+			name:   c.Key(),
+			id:     utils.Sum64(c.Key()),
+			level:  len(levels),
+			weight: c.Weight,
+			choice: c,
+		})
+	}
+	m.root.sum()
+	return m, nil
+}
+
+// child returns the child bucket of the name, adding it if needed.
+func (b *bucket) child(name, path string, level int) *bucket {
+	for _, c := range b.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &bucket{name: name, id: utils.Sum64(path), level: level}
+	b.children = append(b.children, c)
+	return c
+}
+
+// sum computes the weights of the buckets that are not fixed.
+func (b *bucket) sum() int {
+	if b.choice != nil {
+		return b.weight
+	}
+	total := 0
+	for _, c := range b.children {
+		total += c.sum()
+	}
+	if !b.fixed {
+		b.weight = total
+	}
+	return b.weight
+}
+
+// SetWeight overrides the weight of the bucket at the path, e.g. SetWeight(0, "eu", "rack-1")
+// drains a rack without touching the weights of its hosts. A negative weight restores the sum
+// of the children.
+func (m *Map) SetWeight(weight int, path ...string) error {
+	if len(path) == 0 || len(path) > len(m.levels) {
+		return fmt.Errorf("%w: %v", ErrUnknownLevel, path)
+	}
+	b := m.root
+	for _, name := range path {
+		var next *bucket
+		for _, c := range b.children {
+			if c.name == name {
+				next = c
+			}
+		}
+		if next == nil {
+			return fmt.Errorf("%w: %v", ErrUnknownBucket, path)
+		}
+		b = next
+	}
+	b.fixed = weight >= 0
+	b.weight = weight
+	m.root.sum()
+	return nil
+}
+
+// Levels returns the levels of the hierarchy, from the top.
+func (m *Map) Levels() []string {
+	return m.levels
+}
+
+// Place returns the ordered replicas of the key under the rule, fewer if the hierarchy
+// does not have enough available failure domains. Choices that are not Available are skipped.
+func (m *Map) Place(rule Rule, key ...string) ([]*balancer.Choice, error) {
+	domain := len(m.levels)
+	if rule.FailureDomain != "" {
+		domain = -1
+		for i, level := range m.levels {
+			if level == rule.FailureDomain {
+				domain = i
+			}
+		}
+		if domain < 0 {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownLevel, rule.FailureDomain)
+		}
+	}
+	if rule.Replicas <= 0 {
+		return nil, nil
+	}
+
+	x := utils.HashString(key...)
+	replicas := make([]*balancer.Choice, 0, rule.Replicas)
+	domains := make([]*bucket, 0, rule.Replicas)
+	for r := 0; r < rule.Replicas; r++ {
+		for try := 0; try < maxTries; try++ {
+			// the retries of later replicas do not disturb the earlier ones
+			rr := uint64(r + try*rule.Replicas)
+			d := m.root.descend(x, rr, domain)
+			if d == nil || contains(domains, d) {
+				continue
+			}
+			leaf := d.descend(x, rr, len(m.levels))
+			if leaf == nil || !leaf.choice.Available() {
+				continue
+			}
+			domains = append(domains, d)
+			replicas = append(replicas, leaf.choice)
+			break
+		}
+	}
+	return replicas, nil
+}
+
+// PlaceItems is like Place, but returns the items of the choices.
+func (m *Map) PlaceItems(rule Rule, key ...string) ([]interface{}, error) {
+	replicas, err := m.Place(rule, key...)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]interface{}, len(replicas))
+	for i, c := range replicas {
+		items[i] = c.Item
+	}
+	return items, nil
+}
+
+// descend chooses a child with straw2 at each level until the level, nil for an empty bucket.
+func (b *bucket) descend(x, r uint64, level int) *bucket {
+	for b.level < level {
+		b = b.straw2(x, r)
+		if b == nil {
+			return nil
+		}
+	}
+	return b
+}
+
+// straw2 draws a straw for each child, scaled by its weight, the longest wins.
+// A change of weight only changes the straws of that child, so only moves keys to or from it.
+func (b *bucket) straw2(x, r uint64) *bucket {
+	var (
+		best *bucket
+		max  = math.Inf(-1)
+	)
+	for _, c := range b.children {
+		if c.weight <= 0 {
+			continue
+		}
+		// u in (0, 1], ln(u) in (-inf, 0]
+		u := float64(mix(x, c.id, r)>>11+1) / (1 << 53)
+		if draw := math.Log(u) / float64(c.weight); best == nil || draw > max {
+			best, max = c, draw
+		}
+	}
+	return best
+}
+
+func contains(buckets []*bucket, b *bucket) bool {
+	for _, v := range buckets {
+		if v == b {
+			return true
+		}
+	}
+	return false
+}
+
+// mix hashes the key, the bucket id and the replica number.
+func mix(x, id, r uint64) uint64 {
+	h := x ^ fmix64(id+0x9e3779b97f4a7c15*(r+1))
+	return fmix64(h)
+}
+
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+// String prints the hierarchy with the weights, for debugging.
+func (m *Map) String() string {
+	var sb strings.Builder
+	var walk func(b *bucket, depth int)
+	walk = func(b *bucket, depth int) {
+		children := append([]*bucket(nil), b.children...)
+		sort.Slice(children, func(i, j int) bool { return children[i].name < children[j].name })
+		for _, c := range children {
+			level := "item"
+			if c.level < len(m.levels) {
+				level = m.levels[c.level]
+			}
+			fmt.Fprintf(&sb, "%s%s %s weight=%d\n", strings.Repeat("  ", depth), level, c.name, c.weight)
+			walk(c, depth+1)
+		}
+	}
+	walk(m.root, 0)
+	return sb.String()
+}
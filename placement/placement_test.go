@@ -0,0 +1,211 @@
+package placement
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	balancer "github.com/shibingli/load-balancer"
+)
+
+var levels = []string{"region", "rack", "host"}
+
+// genDisks returns 2 regions of 3 racks of 4 hosts of 2 disks.
+func genDisks() []*balancer.Choice {
+	var disks []*balancer.Choice
+	for region := 0; region < 2; region++ {
+		for rack := 0; rack < 3; rack++ {
+			for host := 0; host < 4; host++ {
+				for disk := 0; disk < 2; disk++ {
+					disks = append(disks, &balancer.Choice{
+						Item:   fmt.Sprintf("r%d-k%d-h%d-d%d", region, rack, host, disk),
+						Weight: 100,
+						Labels: map[string]string{
+							"region": "r" + strconv.Itoa(region),
+							"rack":   fmt.Sprintf("r%d-k%d", region, rack),
+							"host":   fmt.Sprintf("r%d-k%d-h%d", region, rack, host),
+						},
+					})
+				}
+			}
+		}
+	}
+	return disks
+}
+
+func place(t *testing.T, m *Map, rule Rule, keys int) [][]*balancer.Choice {
+	t.Helper()
+	all := make([][]*balancer.Choice, keys)
+	for i := range all {
+		replicas, err := m.Place(rule, "object-"+strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		all[i] = replicas
+	}
+	return all
+}
+
+func TestPlace(t *testing.T) {
+	m, err := New(levels, genDisks())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule := Rule{Replicas: 3, FailureDomain: "rack"}
+	count := make(map[*balancer.Choice]int)
+	for _, replicas := range place(t, m, rule, 10000) {
+		if len(replicas) != 3 {
+			t.Fatalf("placement expected 3 replicas, actual %d", len(replicas))
+		}
+		racks := make(map[string]bool)
+		for _, c := range replicas {
+			racks[c.Labels["rack"]] = true
+			count[c]++
+		}
+		if len(racks) != 3 {
+			t.Fatal("placement expected distinct racks")
+		}
+	}
+
+	// 30000 replicas on 48 disks of the same weight
+	for c, n := range count {
+		if n < 450 || n > 800 {
+			t.Fatalf("placement distribution wrong: %v %d", c.Item, n)
+		}
+	}
+
+	again, _ := m.Place(rule, "object-1")
+	first := place(t, m, rule, 2)[1]
+	for i := range again {
+		if again[i] != first[i] {
+			t.Fatal("placement expected the same replicas")
+		}
+	}
+
+	// 2 regions only hold 2 replicas
+	replicas, err := m.Place(Rule{Replicas: 3, FailureDomain: "region"}, "k")
+	if err != nil || len(replicas) != 2 || replicas[0].Labels["region"] == replicas[1].Labels["region"] {
+		t.Fatalf("placement wrong: %v", replicas)
+	}
+
+	// distinct disks by default
+	replicas, _ = m.Place(Rule{Replicas: 5}, "k")
+	if len(replicas) != 5 {
+		t.Fatal("placement expected 5 replicas")
+	}
+
+	if _, err := m.Place(Rule{Replicas: 1, FailureDomain: "row"}, "k"); !errors.Is(err, ErrUnknownLevel) {
+		t.Fatal("placement expected ErrUnknownLevel")
+	}
+	if _, err := New([]string{"region", "row"}, genDisks()); !errors.Is(err, ErrMissingLabel) {
+		t.Fatal("placement expected ErrMissingLabel")
+	}
+}
+
+func TestPlace_Weights(t *testing.T) {
+	disks := genDisks()
+	disks[0].Weight = 300
+	m, _ := New(levels, disks)
+	count := make(map[*balancer.Choice]int)
+	for _, replicas := range place(t, m, Rule{Replicas: 1, FailureDomain: "host"}, 20000) {
+		count[replicas[0]]++
+	}
+	// 20000 keys on a weight of 5000, disks[0] 300, disks[1] 100
+	if count[disks[0]] < 1000 || count[disks[0]] > 1400 || count[disks[1]] < 280 || count[disks[1]] > 520 {
+		t.Fatalf("placement weights wrong: %d %d", count[disks[0]], count[disks[1]])
+	}
+}
+
+// TestPlace_Stable changes weights and checks that replicas only move to or from the subtree
+// of the changed bucket: a heavier disk makes its host, rack and region heavier too.
+func TestPlace_Stable(t *testing.T) {
+	const keys = 5000
+	rule := Rule{Replicas: 1, FailureDomain: "host"}
+	disks := genDisks()
+	m, _ := New(levels, disks)
+	before := place(t, m, rule, keys)
+
+	// a disk gets heavier: keys only move into its region, and few of them
+	disks[5].Weight = 200
+	m2, _ := New(levels, disks)
+	after := place(t, m2, rule, keys)
+	moved := 0
+	for i := range after {
+		if after[i][0] != before[i][0] {
+			if after[i][0].Labels["region"] != disks[5].Labels["region"] {
+				t.Fatalf("placement moved a key from %v to %v", before[i][0].Item, after[i][0].Item)
+			}
+			moved++
+		}
+	}
+	// the weight grew by 2%
+	if moved == 0 || moved > keys/10 {
+		t.Fatalf("placement moved %d keys", moved)
+	}
+	disks[5].Weight = 100
+
+	// a rack is drained: its keys move, and the lighter region loses some keys to the other
+	if err := m.SetWeight(0, "r0", "r0-k1"); err != nil {
+		t.Fatal(err)
+	}
+	after = place(t, m, rule, keys)
+	moved = 0
+	for i := range after {
+		if after[i][0].Labels["rack"] == "r0-k1" {
+			t.Fatal("placement expected the rack to be drained")
+		}
+		if after[i][0] == before[i][0] || before[i][0].Labels["rack"] == "r0-k1" {
+			continue
+		}
+		if before[i][0].Labels["region"] != "r0" || after[i][0].Labels["region"] != "r1" {
+			t.Fatalf("placement moved a key from %v to %v", before[i][0].Item, after[i][0].Item)
+		}
+		moved++
+	}
+	// a sixth of the keys are drained, r0 then gets 2/5 instead of 1/2
+	if moved > keys/6 {
+		t.Fatalf("placement moved %d keys", moved)
+	}
+
+	// and restored
+	if err := m.SetWeight(-1, "r0", "r0-k1"); err != nil {
+		t.Fatal(err)
+	}
+	after = place(t, m, rule, keys)
+	for i := range after {
+		if after[i][0] != before[i][0] {
+			t.Fatal("placement expected the same replicas after restoring the weight")
+		}
+	}
+
+	if err := m.SetWeight(0, "r0", "nope"); !errors.Is(err, ErrUnknownBucket) {
+		t.Fatal("placement expected ErrUnknownBucket")
+	}
+}
+
+func TestPlace_Unavailable(t *testing.T) {
+	disks := genDisks()
+	m, _ := New(levels, disks)
+	c := balancer.NewCluster(balancer.NewRoundRobin(), disks...)
+	rule := Rule{Replicas: 3, FailureDomain: "rack"}
+
+	before := place(t, m, rule, 1000)
+	c.Eject(disks[0], "down")
+	after := place(t, m, rule, 1000)
+	for i := range after {
+		for j, d := range after[i] {
+			if d == disks[0] {
+				t.Fatal("placement selected an ejected choice")
+			}
+			// the primary only moves off the ejected disk
+			if j == 0 && d != before[i][0] && before[i][0] != disks[0] {
+				t.Fatalf("placement moved a primary from %v to %v", before[i][0].Item, d.Item)
+			}
+		}
+		if len(after[i]) != 3 {
+			t.Fatal("placement expected 3 replicas")
+		}
+	}
+}
@@ -1,39 +1,109 @@
 package balancer
 
-import (
-	"github.com/shibingli/load-balancer/utils"
-)
-
 // Random
 type random struct {
 	items []*Choice
 	count uint32
+	src   Source
 }
 
 func NewRandom(choices ...*Choice) (lb *random) {
-	lb = &random{}
+	return NewRandomWithSource(nil, choices...)
+}
+
+// NewRandomWithSource create a Random balancer drawing from src, see NewSource.
+// A nil src uses the default runtime source.
+func NewRandomWithSource(src Source, choices ...*Choice) (lb *random) {
+	lb = &random{src: sourceOrDefault(src)}
 	lb.Update(choices)
 	return
 }
 
 func (b *random) Select(_ ...string) (item interface{}) {
+	item, _ = b.SelectE()
+	return
+}
+
+func (b *random) SelectE(_ ...string) (interface{}, error) {
+	c, err := b.SelectChoice()
+	if err != nil {
+		return nil, err
+	}
+	return c.Item, nil
+}
+
+func (b *random) SelectChoice(_ ...string) (*Choice, error) {
 	switch b.count {
 	case 0:
-		item = nil
+		return nil, ErrNoChoices
 	case 1:
-		item = b.items[0].Item
+		if !b.items[0].Available() {
+			return nil, unavailable(b.items)
+		}
+		return b.items[0], nil
 	default:
-		item = b.items[utils.FastRandn(b.count)].Item
+		c := b.items[b.src.Uint32n(b.count)]
+		if c.Available() {
+			return c, nil
+		}
+		return b.chooseAvailable()
 	}
-	return
+}
+
+func (b *random) SelectN(n int, key ...string) []interface{} {
+	return choiceItems(b.selectN(n, key))
+}
+
+func (b *random) selectN(n int, _ []string) []*Choice {
+	return sampleDistinct(n, b.items, b.src, unitWeight, func() *Choice {
+		c, _ := b.SelectChoice()
+		return c
+	})
+}
+
+// chooseAvailable picks uniformly among the available items, the slow path of SelectChoice.
+func (b *random) chooseAvailable() (*Choice, error) {
+	n := uint32(0)
+	for i := range b.items {
+		if b.items[i].Available() {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil, unavailable(b.items)
+	}
+
+	r := b.src.Uint32n(n)
+	for i := range b.items {
+		if b.items[i].Available() {
+			if r == 0 {
+				return b.items[i], nil
+			}
+			r--
+		}
+	}
+	return nil, unavailable(b.items)
 }
 
 func (b *random) Name() string {
 	return "Random"
 }
 
+// Choices returns all items.
+func (b *random) Choices() []*Choice {
+	return b.items
+}
+
 func (b *random) Update(choices []*Choice) bool {
-	b.items = choices
-	b.count = uint32(len(choices))
-	return true
+	return b.UpdateE(choices) == nil
+}
+
+func (b *random) UpdateE(choices []*Choice) error {
+	items, n := cleanChoices(choices)
+	b.items = items
+	b.count = uint32(n)
+	if n == 0 {
+		return ErrNoChoices
+	}
+	return nil
 }
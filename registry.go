@@ -0,0 +1,172 @@
+package balancer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Factory creates a balancer with or without items.
+type Factory func(choices ...*Choice) Balancer
+
+type mode struct {
+	name    string
+	factory Factory
+}
+
+type modeRegistry struct {
+	sync.RWMutex
+	modes []mode
+	names map[string]Mode
+}
+
+var registry = newModeRegistry()
+
+func newModeRegistry() *modeRegistry {
+	r := &modeRegistry{names: make(map[string]Mode)}
+	r.add(WeightedRoundRobin, func(c ...*Choice) Balancer { return NewWeightedRoundRobin(c...) },
+		"wrr", "WeightedRoundRobin")
+	r.add(SmoothWeightedRoundRobin, func(c ...*Choice) Balancer { return NewSmoothWeightedRoundRobin(c...) },
+		"swrr", "SmoothWeightedRoundRobin")
+	r.add(WeightedRand, func(c ...*Choice) Balancer { return NewWeightedRand(c...) },
+		"wr", "WeightedRand")
+	r.add(ConsistentHash, func(c ...*Choice) Balancer { return NewConsistentHash(c...) },
+		"hash", "ConsistentHash")
+	r.add(RoundRobin, func(c ...*Choice) Balancer { return NewRoundRobin(c...) },
+		"rr", "RoundRobin")
+	r.add(Random, func(c ...*Choice) Balancer { return NewRandom(c...) },
+		"random", "Random")
+	r.add(WeightedRandAlias, func(c ...*Choice) Balancer { return NewWeightedRandAlias(c...) },
+		"wralias", "WeightedRandAlias")
+	r.add(MultiProbeConsistentHash, func(c ...*Choice) Balancer { return NewMultiProbeConsistentHash(c...) },
+		"mphash", "MultiProbeConsistentHash")
+	r.add(AnchorHash, func(c ...*Choice) Balancer { return NewAnchorHash(c...) },
+		"anchor", "AnchorHash")
+	r.add(RendezvousHash, func(c ...*Choice) Balancer { return NewRendezvousHash(c...) },
+		"rendezvous", "RendezvousHash")
+	return r
+}
+
+// add registers a built-in mode, the first name is used by Mode.String.
+func (r *modeRegistry) add(m Mode, factory Factory, names ...string) {
+	if int(m) != len(r.modes) {
+		panic("balancer: built-in modes must be registered in order")
+	}
+	r.modes = append(r.modes, mode{name: names[0], factory: factory})
+	for _, name := range names {
+		r.names[strings.ToLower(name)] = m
+	}
+}
+
+// Register makes a balancer algorithm available by the provided name and returns its Mode.
+// Names are case-insensitive. If Register is called twice with the same name, or if factory
+// is nil, it panics.
+func Register(name string, factory Factory) Mode {
+	if name == "" {
+		panic("balancer: Register name is empty")
+	}
+	if factory == nil {
+		panic("balancer: Register factory is nil")
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	key := strings.ToLower(name)
+	if _, ok := registry.names[key]; ok {
+		panic("balancer: Register called twice for mode " + name)
+	}
+	m := Mode(len(registry.modes))
+	registry.modes = append(registry.modes, mode{name: name, factory: factory})
+	registry.names[key] = m
+	return m
+}
+
+// Modes returns the names of all registered modes in registration order.
+func Modes() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	names := make([]string, len(registry.modes))
+	for i := range registry.modes {
+		names[i] = registry.modes[i].name
+	}
+	return names
+}
+
+// ParseMode returns the Mode registered under the name, e.g. "wrr" or "WeightedRoundRobin".
+func ParseMode(name string) (Mode, error) {
+	registry.RLock()
+	m, ok := registry.names[strings.ToLower(strings.TrimSpace(name))]
+	registry.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownMode, name)
+	}
+	return m, nil
+}
+
+func lookupMode(m Mode) (mode, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	if m < 0 || int(m) >= len(registry.modes) {
+		return mode{}, false
+	}
+	return registry.modes[m], true
+}
+
+// String returns the registered name of the mode.
+func (m Mode) String() string {
+	if v, ok := lookupMode(m); ok {
+		return v.name
+	}
+	return "Mode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m Mode) MarshalText() ([]byte, error) {
+	if _, ok := lookupMode(m); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMode, m)
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *Mode) UnmarshalText(text []byte) error {
+	v, err := ParseMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// New create a balancer with or without items.
+// A mode that has not been registered falls back to WeightedRoundRobin, as it always has:
+// use NewE to get ErrUnknownMode instead.
+func New(b Mode, choices []*Choice) Balancer {
+	lb, err := NewE(b, choices)
+	if err != nil {
+		lb, _ = NewE(WeightedRoundRobin, choices)
+	}
+	return lb
+}
+
+// NewE create a balancer of a registered mode with or without items, or returns ErrUnknownMode.
+func NewE(b Mode, choices []*Choice) (Balancer, error) {
+	v, ok := lookupMode(b)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMode, b)
+	}
+	return v.factory(choices...), nil
+}
+
+// NewByName create a balancer of the mode registered under the name, with or without items.
+func NewByName(name string, choices []*Choice) (Balancer, error) {
+	m, err := ParseMode(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewE(m, choices)
+}
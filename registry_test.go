@@ -0,0 +1,165 @@
+package balancer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type firstChoice struct {
+	items []*Choice
+}
+
+func (b *firstChoice) Select(_ ...string) interface{} {
+	if len(b.items) == 0 {
+		return nil
+	}
+	return b.items[0].Item
+}
+
+func (b *firstChoice) SelectE(_ ...string) (interface{}, error) {
+	c, err := b.SelectChoice()
+	if err != nil {
+		return nil, err
+	}
+	return c.Item, nil
+}
+
+func (b *firstChoice) SelectChoice(_ ...string) (*Choice, error) {
+	if len(b.items) == 0 {
+		return nil, ErrNoChoices
+	}
+	return b.items[0], nil
+}
+
+func (b *firstChoice) SelectN(n int, _ ...string) []interface{} {
+	var items []interface{}
+	for i := 0; i < n && i < len(b.items); i++ {
+		items = append(items, b.items[i].Item)
+	}
+	return items
+}
+
+func (b *firstChoice) Name() string {
+	return "First"
+}
+
+func (b *firstChoice) Update(choices []*Choice) bool {
+	return b.UpdateE(choices) == nil
+}
+
+func (b *firstChoice) UpdateE(choices []*Choice) error {
+	b.items = choices
+	if len(choices) == 0 {
+		return ErrNoChoices
+	}
+	return nil
+}
+
+func TestMode(t *testing.T) {
+	for m, name := range map[Mode]string{
+		WeightedRoundRobin:       "wrr",
+		SmoothWeightedRoundRobin: "swrr",
+		WeightedRand:             "wr",
+		ConsistentHash:           "hash",
+		RoundRobin:               "rr",
+		Random:                   "random",
+		WeightedRandAlias:        "wralias",
+		MultiProbeConsistentHash: "mphash",
+		AnchorHash:               "anchor",
+		RendezvousHash:           "rendezvous",
+	} {
+		if m.String() != name {
+			t.Fatalf("mode expected %s, actual %s", name, m)
+		}
+		v, err := ParseMode(name)
+		if err != nil || v != m {
+			t.Fatalf("mode parse wrong: %s", name)
+		}
+		v, err = ParseMode(New(m, nil).Name())
+		if err != nil || v != m {
+			t.Fatalf("mode parse wrong: %s", New(m, nil).Name())
+		}
+	}
+
+	if _, err := ParseMode("maglev"); !errors.Is(err, ErrUnknownMode) {
+		t.Fatal("mode expected ErrUnknownMode")
+	}
+	if Mode(-1).String() != "Mode(-1)" {
+		t.Fatal("mode string wrong")
+	}
+
+	var cfg struct {
+		Mode Mode `json:"mode"`
+	}
+	if err := json.Unmarshal([]byte(`{"mode": "SWRR"}`), &cfg); err != nil || cfg.Mode != SmoothWeightedRoundRobin {
+		t.Fatal("mode unmarshal wrong")
+	}
+	if err := json.Unmarshal([]byte(`{"mode": "unknown"}`), &cfg); !errors.Is(err, ErrUnknownMode) {
+		t.Fatal("mode expected ErrUnknownMode")
+	}
+	b, err := json.Marshal(cfg)
+	if err != nil || string(b) != `{"mode":"swrr"}` {
+		t.Fatalf("mode marshal wrong: %s", b)
+	}
+}
+
+var firstMode = Register("first", func(choices ...*Choice) Balancer {
+	lb := &firstChoice{}
+	lb.Update(choices)
+	return lb
+})
+
+func TestRegister(t *testing.T) {
+	m := firstMode
+	if m.String() != "first" {
+		t.Fatalf("register expected first, actual %s", m)
+	}
+
+	lb, err := NewByName("First", NewChoicesSlice([]string{"A", "B"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lb.Name() != "First" || lb.Select() != "A" {
+		t.Fatal("register wrong")
+	}
+	if New(m, nil).Select() != nil {
+		t.Fatal("register wrong")
+	}
+
+	found := false
+	for _, name := range Modes() {
+		found = found || name == "first"
+	}
+	if !found {
+		t.Fatal("modes wrong")
+	}
+
+	if _, err := NewByName("maglev", nil); !errors.Is(err, ErrUnknownMode) {
+		t.Fatal("register expected ErrUnknownMode")
+	}
+	if New(Mode(1<<20), nil).Name() != "WeightedRoundRobin" {
+		t.Fatal("register expected the WeightedRoundRobin fallback")
+	}
+	if lb, err := NewE(Mode(1<<20), nil); lb != nil || !errors.Is(err, ErrUnknownMode) {
+		t.Fatalf("register expected ErrUnknownMode, actual %v", err)
+	}
+	if lb, err := NewE(RoundRobin, nil); err != nil || lb.Name() != "RoundRobin" {
+		t.Fatalf("register expected RoundRobin, actual %v", err)
+	}
+
+	mustPanic(t, func() { Register("FIRST", func(...*Choice) Balancer { return nil }) })
+	mustPanic(t, func() { Register("wrr", func(...*Choice) Balancer { return nil }) })
+	mustPanic(t, func() { Register("", func(...*Choice) Balancer { return nil }) })
+	mustPanic(t, func() { Register("nil", nil) })
+}
+
+func mustPanic(t *testing.T, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	f()
+}
@@ -0,0 +1,131 @@
+package balancer
+
+import (
+	"github.com/shibingli/load-balancer/hasher"
+	"github.com/shibingli/load-balancer/utils"
+)
+
+// RendezvousHash (highest random weight) goes to the item of the highest score
+// mix64(hash(key) ^ mix64(FNV-1a(Key()))): the placement only depends on the set of the items,
+// and adding or removing any item only moves the keys it takes over or owned.
+// A selection scores every item, for thousands of items see MultiProbeConsistentHash and AnchorHash.
+type rendezvousHash struct {
+	// items sorted by identity, the first of equal scores wins
+	sorted []*Choice
+	// hashes of the identities of sorted
+	ids []uint64
+	hashBalancer
+}
+
+func NewRendezvousHash(choices ...*Choice) (lb *rendezvousHash) {
+	return NewRendezvousHashWithHasher(nil, choices...)
+}
+
+// NewRendezvousHashWithHasher create a RendezvousHash balancer hashing the keys with h,
+// e.g. hasher.XXHash64{}. A nil h uses FNV-1a.
+func NewRendezvousHashWithHasher(h hasher.Hasher, choices ...*Choice) (lb *rendezvousHash) {
+	lb = &rendezvousHash{hashBalancer: hashBalancer{keyHasher: keyHasher{h}}}
+	lb.placement = lb
+	lb.Update(choices)
+	return
+}
+
+func (b *rendezvousHash) chooseN(n int, hash uint64) []*Choice {
+	// the available items of the n highest scores, by insertion
+	choices := make([]*Choice, 0, n)
+	scores := make([]uint64, 0, n)
+	for i, c := range b.sorted {
+		if !c.Available() {
+			continue
+		}
+		score := mix64(hash ^ b.ids[i])
+		j := len(choices)
+		for j > 0 && score > scores[j-1] {
+			j--
+		}
+		if j == n {
+			continue
+		}
+		if len(choices) < n {
+			choices, scores = append(choices, nil), append(scores, 0)
+		}
+		copy(choices[j+1:], choices[j:])
+		copy(scores[j+1:], scores[j:])
+		choices[j], scores[j] = c, score
+	}
+	if len(choices) == 0 {
+		return nil
+	}
+	return choices
+}
+
+// choose returns the owner of the hash, or the available item of the next highest score
+// if the owner is not available.
+func (b *rendezvousHash) choose(hash uint64) (*Choice, error) {
+	var (
+		best  *Choice
+		score uint64
+	)
+	for i, c := range b.sorted {
+		if s := mix64(hash ^ b.ids[i]); (best == nil || s > score) && c.Available() {
+			best, score = c, s
+		}
+	}
+	if best == nil {
+		return nil, unavailable(b.items)
+	}
+	return best, nil
+}
+
+// SetHasher replaces the hasher of the keys.
+// Like Update, it must not be called concurrently with a selection.
+func (b *rendezvousHash) SetHasher(h hasher.Hasher) {
+	b.hasher = h
+}
+
+func (b *rendezvousHash) Name() string {
+	return "RendezvousHash"
+}
+
+func (b *rendezvousHash) Update(choices []*Choice) bool {
+	return b.UpdateE(choices) == nil
+}
+
+func (b *rendezvousHash) UpdateE(choices []*Choice) error {
+	b.items, b.count = cleanChoices(choices)
+	b.labels = &labelCounts{}
+	b.sorted = sortByID(b.items)
+	b.ids = make([]uint64, len(b.sorted))
+	for i, c := range b.sorted {
+		b.ids[i] = mix64(utils.Sum64(c.Key()))
+	}
+	if b.count == 0 {
+		return ErrNoChoices
+	}
+	return nil
+}
+
+// Ownership estimates the ownership, see Analyzer.
+func (b *rendezvousHash) Ownership() Ownership {
+	return sampleOwnership(b.items, b.owner)
+}
+
+// Remap estimates the keys moving by an Update with the choices, see Analyzer.
+func (b *rendezvousHash) Remap(choices []*Choice) Remap {
+	next := NewRendezvousHashWithHasher(b.hasher, choices...)
+	return sampleRemap(b.owner, next.owner)
+}
+
+// owner returns the item of the highest score regardless of availability, nil without items.
+func (b *rendezvousHash) owner(hash uint64) *Choice {
+	var (
+		best  *Choice
+		score uint64
+	)
+	for i, c := range b.sorted {
+		if s := mix64(hash ^ b.ids[i]); best == nil || s > score {
+			best, score = c, s
+		}
+	}
+	return best
+}
@@ -0,0 +1,135 @@
+package balancer
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestRendezvousHash(t *testing.T) {
+	lb := NewRendezvousHash()
+	if item := lb.Select("a"); item != nil {
+		t.Fatalf("rendezvous expected nil, actual %s", item)
+	}
+	if _, err := lb.SelectE("a"); err != ErrNoChoices {
+		t.Fatalf("rendezvous expected ErrNoChoices, actual %v", err)
+	}
+
+	choices := NewChoicesSlice([]string{"A", "B", "C", "D"})
+	lb = NewRendezvousHash(choices...)
+	if _, err := lb.SelectE(); err != ErrKeyRequired {
+		t.Fatalf("rendezvous expected ErrKeyRequired, actual %v", err)
+	}
+	count := make(map[interface{}]int)
+	for i := 0; i < 1000; i++ {
+		key := "192.168.1." + strconv.Itoa(i)
+		item := lb.Select(key)
+		if lb.Select(key) != item || lb.SelectBytes([]byte(key)) != item {
+			t.Fatal("rendezvous expected the same item")
+		}
+		count[item]++
+	}
+	if len(count) != 4 {
+		t.Fatalf("rendezvous wrong: %v", count)
+	}
+
+	// the keys of an ejected item go to their next highest score
+	owner := lb.Select("key")
+	next := lb.SelectN(2, "key")[1]
+	for _, c := range choices {
+		if c.Item == owner {
+			c.ejected = 1
+		}
+	}
+	if item := lb.Select("key"); item != next {
+		t.Fatalf("rendezvous expected %v, actual %v", next, item)
+	}
+}
+
+func TestRendezvousHash_Stable(t *testing.T) {
+	nodes := make([]string, 11)
+	for i := range nodes {
+		nodes[i] = "cache-" + strconv.Itoa(i)
+	}
+	// cache-10 sorts between cache-1 and cache-2
+	before := NewRendezvousHash(NewChoicesSlice(nodes[:10])...)
+	added := NewRendezvousHash(NewChoicesSlice(nodes)...)
+	removed := NewRendezvousHash(NewChoicesSlice(append(append([]string(nil), nodes[:3]...), nodes[4:10]...))...)
+
+	const keys = 100000
+	var toAdded, fromRemoved int
+	for i := 0; i < keys; i++ {
+		key := "key-" + strconv.Itoa(i)
+		owner := before.Select(key)
+		if item := added.Select(key); item != owner {
+			if item != "cache-10" {
+				t.Fatalf("rendezvous moved %s from %v to %v adding cache-10", key, owner, item)
+			}
+			toAdded++
+		}
+		if item := removed.Select(key); item != owner {
+			if owner != "cache-3" {
+				t.Fatalf("rendezvous moved %s from %v to %v removing cache-3", key, owner, item)
+			}
+			fromRemoved++
+		} else if owner == "cache-3" {
+			t.Fatalf("rendezvous kept %s on the removed cache-3", key)
+		}
+	}
+	// a share of 1/11 and 1/10 of the keys
+	if toAdded < keys/11-1000 || toAdded > keys/11+1000 || fromRemoved < keys/10-1000 || fromRemoved > keys/10+1000 {
+		t.Fatalf("rendezvous moved %d keys adding and %d removing", toAdded, fromRemoved)
+	}
+}
+
+// TestRendezvousHash_Vectors checks the placement against testdata/rendezvous_hash_vectors.json,
+// generated by the reference implementation testdata/rendezvous_hash_vectors.py.
+func TestRendezvousHash_Vectors(t *testing.T) {
+	b, err := os.ReadFile("testdata/rendezvous_hash_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors struct {
+		Cases []struct {
+			Nodes []string `json:"nodes"`
+			Keys  []struct {
+				Key      string   `json:"key"`
+				Owner    string   `json:"owner"`
+				Replicas []string `json:"replicas"`
+			} `json:"keys"`
+		} `json:"cases"`
+	}
+	if err := json.Unmarshal(b, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors.Cases) == 0 {
+		t.Fatal("rendezvous expected test vectors")
+	}
+
+	for _, c := range vectors.Cases {
+		// any order of the nodes
+		nodes := append([]string(nil), c.Nodes...)
+		for round := 0; round < 3; round++ {
+			lb := NewRendezvousHash(NewChoicesSlice(nodes)...)
+			for _, v := range c.Keys {
+				if item := lb.Select(v.Key); item != v.Owner {
+					t.Fatalf("rendezvous of %q expected %s, actual %v", v.Key, v.Owner, item)
+				}
+				replicas := lb.SelectN(3, v.Key)
+				if len(replicas) != len(v.Replicas) {
+					t.Fatalf("rendezvous of %q expected %v, actual %v", v.Key, v.Replicas, replicas)
+				}
+				for i := range replicas {
+					if replicas[i] != v.Replicas[i] {
+						t.Fatalf("rendezvous of %q expected %v, actual %v", v.Key, v.Replicas, replicas)
+					}
+				}
+			}
+			rand.New(rand.NewSource(int64(round))).Shuffle(len(nodes), func(i, j int) {
+				nodes[i], nodes[j] = nodes[j], nodes[i]
+			})
+		}
+	}
+}
@@ -18,28 +18,86 @@ func NewRoundRobin(choices ...*Choice) (lb *rr) {
 }
 
 func (b *rr) Select(_ ...string) (item interface{}) {
+	item, _ = b.SelectE()
+	return
+}
+
+func (b *rr) SelectE(_ ...string) (interface{}, error) {
+	c, err := b.SelectChoice()
+	if err != nil {
+		return nil, err
+	}
+	return c.Item, nil
+}
+
+func (b *rr) SelectChoice(_ ...string) (*Choice, error) {
 	n := atomic.LoadUint32(&b.count)
 	switch n {
 	case 0:
-		item = nil
+		return nil, ErrNoChoices
 	case 1:
-		item = b.items[0].Item
+		if !b.items[0].Available() {
+			return nil, unavailable(b.items)
+		}
+		return b.items[0], nil
 	default:
 		m := atomic.LoadUint32(&b.current)
-		item = b.items[m].Item
-		m = (m + 1) % n
-		atomic.StoreUint32(&b.current, m)
+		for i := uint32(0); i < n; i++ {
+			c := b.items[(m+i)%n]
+			if c.Available() {
+				atomic.StoreUint32(&b.current, (m+i+1)%n)
+				return c, nil
+			}
+		}
+		return nil, unavailable(b.items)
 	}
-	return
+}
+
+func (b *rr) SelectN(n int, key ...string) []interface{} {
+	return choiceItems(b.selectN(n, key))
+}
+
+// selectN takes the next n available items of the rotation.
+func (b *rr) selectN(n int, _ []string) []*Choice {
+	count := atomic.LoadUint32(&b.count)
+	if n <= 0 || count == 0 {
+		return nil
+	}
+	if n > int(count) {
+		n = int(count)
+	}
+
+	choices := make([]*Choice, 0, n)
+	m := atomic.LoadUint32(&b.current)
+	for i := uint32(0); i < count && len(choices) < n; i++ {
+		if c := b.items[(m+i)%count]; c.Available() {
+			choices = append(choices, c)
+			atomic.StoreUint32(&b.current, (m+i+1)%count)
+		}
+	}
+	return choices
 }
 
 func (b *rr) Name() string {
 	return "RoundRobin"
 }
 
+// Choices returns all items.
+func (b *rr) Choices() []*Choice {
+	return b.items
+}
+
 func (b *rr) Update(choices []*Choice) bool {
-	b.items = choices
-	b.count = uint32(len(choices))
+	return b.UpdateE(choices) == nil
+}
+
+func (b *rr) UpdateE(choices []*Choice) error {
+	items, n := cleanChoices(choices)
+	b.items = items
+	b.count = uint32(n)
 	b.current = 0
-	return true
+	if n == 0 {
+		return ErrNoChoices
+	}
+	return nil
 }
@@ -0,0 +1,112 @@
+package balancer
+
+import (
+	"sync/atomic"
+
+	"github.com/shibingli/load-balancer/utils"
+)
+
+// DefaultScheduleSize is a reasonable maximum length of a precomputed schedule,
+// see NewWeightedRoundRobinWithSchedule.
+const DefaultScheduleSize = 1 << 16
+
+// maxSWRRScheduleWork bounds the cycle * choices steps of newSWRRSchedule, tens of milliseconds.
+const maxSWRRScheduleWork = 1 << 24
+
+// schedule is one precomputed cycle of a round-robin balancer,
+// selection is an atomic increment of the index and is goroutine-safe.
+type schedule struct {
+	items []*Choice
+	i     uint64
+}
+
+// next returns the next available choice of the cycle, nil if none is available.
+func (s *schedule) next(items []*Choice) *Choice {
+	n := uint64(len(s.items))
+	i := atomic.AddUint64(&s.i, 1) - 1
+	if c := s.items[i%n]; c.Available() {
+		return c
+	}
+	if !anyAvailable(items) {
+		return nil
+	}
+	// keep the order of the cycle, skipping the unavailable choices
+	for j := uint64(1); j < n; j++ {
+		if c := s.items[(i+j)%n]; c.Available() {
+			return c
+		}
+	}
+	return nil
+}
+
+// scheduleLen returns the length of one cycle, or 0 if it is longer than size.
+func scheduleLen(items []*Choice, gcd, size int) int {
+	if size <= 0 || gcd <= 0 {
+		return 0
+	}
+	n := 0
+	for i := range items {
+		n += items[i].Weight / gcd
+		if n > size {
+			return 0
+		}
+	}
+	return n
+}
+
+// newWRRSchedule precomputes the cycle of the LVS weighted round-robin,
+// nil if it is longer than size.
+func newWRRSchedule(items []*Choice, gcd, max, size int) *schedule {
+	n := scheduleLen(items, gcd, size)
+	if n == 0 {
+		return nil
+	}
+	s := &schedule{items: make([]*Choice, 0, n)}
+	for cw := max; cw > 0; cw -= gcd {
+		for i := range items {
+			if items[i].Weight >= cw {
+				s.items = append(s.items, items[i])
+			}
+		}
+	}
+	return s
+}
+
+// newSWRRSchedule precomputes the cycle of the smooth weighted round-robin,
+// nil if it is longer than size or takes more than maxSWRRScheduleWork steps.
+// The CurrentWeight of the choices is left untouched.
+func newSWRRSchedule(items []*Choice, gcd, size int) *schedule {
+	n := scheduleLen(items, gcd, size)
+	if n == 0 || n > maxSWRRScheduleWork/len(items) {
+		return nil
+	}
+	weights := make([]int, len(items))
+	current := make([]int, len(items))
+	total := 0
+	for i := range items {
+		weights[i] = items[i].Weight / gcd
+		total += weights[i]
+	}
+
+	s := &schedule{items: make([]*Choice, n)}
+	for j := range s.items {
+		best := 0
+		for i := range items {
+			current[i] += weights[i]
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		s.items[j] = items[best]
+	}
+	return s
+}
+
+// weightsGCD returns the greatest common divisor of the weights.
+func weightsGCD(items []*Choice) (gcd int) {
+	for i := range items {
+		gcd = utils.GCD(gcd, items[i].Weight)
+	}
+	return
+}
@@ -0,0 +1,137 @@
+package balancer
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSchedule_SameOrder(t *testing.T) {
+	for _, weights := range [][]int{
+		{5, 3, 1, 0},
+		{4, 2, 2},
+		{1, 1, 1},
+		{100, 1, 7, 13},
+	} {
+		newChoices := func() []*Choice {
+			choices := make([]*Choice, len(weights))
+			for i, w := range weights {
+				choices[i] = &Choice{Item: strconv.Itoa(i), Weight: w}
+			}
+			return choices
+		}
+
+		for _, lbs := range [][2]Balancer{
+			{NewWeightedRoundRobin(newChoices()...), NewWeightedRoundRobinWithSchedule(DefaultScheduleSize, newChoices()...)},
+			{NewSmoothWeightedRoundRobin(newChoices()...), NewSmoothWeightedRoundRobinWithSchedule(DefaultScheduleSize, newChoices()...)},
+		} {
+			a, b := lbs[0], lbs[1]
+			if !b.(interface{ Scheduled() bool }).Scheduled() {
+				t.Fatalf("%s expected a schedule", b.Name())
+			}
+			for i := 0; i < 500; i++ {
+				if x, y := a.Select(), b.Select(); x != y {
+					t.Fatalf("%s %v: selection %d expected %v, actual %v", a.Name(), weights, i, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestSchedule_Fallback(t *testing.T) {
+	choices := []*Choice{
+		{Item: "A", Weight: 1000},
+		{Item: "B", Weight: 1},
+	}
+	wrr := NewWeightedRoundRobinWithSchedule(200, choices...)
+	swrr := NewSmoothWeightedRoundRobinWithSchedule(200, choices...)
+	if wrr.Scheduled() || swrr.Scheduled() {
+		t.Fatal("schedule expected fallback")
+	}
+	if wrr.Select() != "A" || swrr.Select() != "A" {
+		t.Fatal("schedule fallback wrong")
+	}
+
+	// the gcd shortens the cycle
+	choices[0].Weight, choices[1].Weight = 1000, 10
+	wrr.Update(choices)
+	swrr.Update(choices)
+	if !wrr.Scheduled() || !swrr.Scheduled() || len(wrr.sched.items) != 101 {
+		t.Fatal("schedule expected a schedule")
+	}
+
+	if NewWeightedRoundRobin(choices...).Scheduled() {
+		t.Fatal("schedule expected no schedule")
+	}
+
+	// a short cycle of too many choices to build in time
+	many := genChoices(5000)
+	for _, c := range many {
+		c.Weight = 1
+	}
+	many[0].Weight = 2
+	swrr = NewSmoothWeightedRoundRobinWithSchedule(1<<20, many...)
+	if swrr.Scheduled() || !NewWeightedRoundRobinWithSchedule(1<<20, many...).Scheduled() {
+		t.Fatal("schedule expected the swrr fallback")
+	}
+	if swrr.Select() != many[0].Item {
+		t.Fatal("schedule fallback wrong")
+	}
+}
+
+func TestSchedule_Skip(t *testing.T) {
+	choices := []*Choice{
+		{Item: "A", Weight: 5},
+		{Item: "B", Weight: 1},
+		{Item: "C", Weight: 2},
+	}
+	lb := NewSmoothWeightedRoundRobinWithSchedule(DefaultScheduleSize, choices...)
+	choices[0].ejected = 1
+	count := make(map[interface{}]int)
+	for i := 0; i < 300; i++ {
+		count[lb.Select()]++
+	}
+	if count["A"] != 0 || count["B"] == 0 || count["C"] <= count["B"] {
+		t.Fatalf("schedule skip wrong: %v", count)
+	}
+
+	choices[1].ejected = 1
+	choices[2].ejected = 1
+	if _, err := lb.SelectE(); err != ErrAllUnhealthy {
+		t.Fatalf("schedule expected ErrAllUnhealthy, actual %v", err)
+	}
+}
+
+func TestSchedule_C(t *testing.T) {
+	choices := []*Choice{
+		{Item: "A", Weight: 5},
+		{Item: "B", Weight: 3},
+		{Item: "C", Weight: 2},
+	}
+	lb := NewWeightedRoundRobinWithSchedule(DefaultScheduleSize, choices...)
+
+	var mu sync.Mutex
+	count := make(map[interface{}]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := make(map[interface{}]int)
+			for j := 0; j < 1000; j++ {
+				local[lb.Select()]++
+			}
+			mu.Lock()
+			for k, v := range local {
+				count[k] += v
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// 100000 selections are exactly 10000 cycles
+	if count["A"] != 50000 || count["B"] != 30000 || count["C"] != 20000 {
+		t.Fatalf("schedule wrong: %v", count)
+	}
+}
@@ -0,0 +1,124 @@
+package balancer
+
+// nSelector is implemented by the built-in balancers, Cluster uses it to record
+// the selections of each choice.
+type nSelector interface {
+	selectN(n int, key []string) []*Choice
+}
+
+// choiceItems returns the items of the choices.
+func choiceItems(choices []*Choice) []interface{} {
+	if len(choices) == 0 {
+		return nil
+	}
+	items := make([]interface{}, len(choices))
+	for i := range choices {
+		items[i] = choices[i].Item
+	}
+	return items
+}
+
+// countAvailable returns the number of available items.
+func countAvailable(items []*Choice) (n int) {
+	for i := range items {
+		if items[i].Available() {
+			n++
+		}
+	}
+	return
+}
+
+// nextDistinct collects the next up to n distinct choices of a rotation, or nil if none is available.
+// With skewed weights the rotation repeats a choice many times, after n * len(items) calls of next
+// the rest is filled with the remaining available items in order, without advancing the rotation.
+func nextDistinct(n int, items []*Choice, next func() *Choice) []*Choice {
+	if want := countAvailable(items); n > want {
+		n = want
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	choices := make([]*Choice, 0, n)
+	seen := make(map[*Choice]struct{}, n)
+	for i := 0; i < n*len(items) && len(choices) < n; i++ {
+		c := next()
+		if c == nil {
+			return choices
+		}
+		if _, ok := seen[c]; !ok {
+			seen[c] = struct{}{}
+			choices = append(choices, c)
+		}
+	}
+	for _, c := range items {
+		if len(choices) == n {
+			break
+		}
+		if _, ok := seen[c]; !ok && c.Available() {
+			seen[c] = struct{}{}
+			choices = append(choices, c)
+		}
+	}
+	return choices
+}
+
+// sampleDistinct draws up to n distinct available choices by weight, without replacement.
+// Draws of next that repeat a choice are rejected, which keeps the distribution exact,
+// after a few rejections the rest is drawn from the remaining choices directly.
+func sampleDistinct(n int, items []*Choice, src Source, weight func(*Choice) int, next func() *Choice) []*Choice {
+	if n <= 0 {
+		return nil
+	}
+	if n > len(items) {
+		n = len(items)
+	}
+
+	choices := make([]*Choice, 0, n)
+	seen := make(map[*Choice]struct{}, n)
+	for i := 0; i < 4*n && len(choices) < n; i++ {
+		c := next()
+		if c == nil {
+			return choices
+		}
+		if _, ok := seen[c]; !ok {
+			seen[c] = struct{}{}
+			choices = append(choices, c)
+		}
+	}
+
+	for len(choices) < n {
+		total := 0
+		for _, c := range items {
+			if _, ok := seen[c]; !ok && c.Available() {
+				total += weight(c)
+			}
+		}
+		if total == 0 {
+			break
+		}
+
+		r := int(src.Uint32n(uint32(total)))
+		for _, c := range items {
+			if _, ok := seen[c]; ok || !c.Available() {
+				continue
+			}
+			if w := weight(c); r >= w {
+				r -= w
+				continue
+			}
+			seen[c] = struct{}{}
+			choices = append(choices, c)
+			break
+		}
+	}
+	return choices
+}
+
+func unitWeight(*Choice) int {
+	return 1
+}
+
+func choiceWeight(c *Choice) int {
+	return c.Weight
+}
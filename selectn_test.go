@@ -0,0 +1,188 @@
+package balancer
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSelectN(t *testing.T) {
+	for _, m := range []Mode{WeightedRoundRobin, SmoothWeightedRoundRobin, WeightedRand, ConsistentHash, RoundRobin, Random, WeightedRandAlias, MultiProbeConsistentHash, AnchorHash, RendezvousHash} {
+		choices := []*Choice{
+			{Item: "A", Weight: 5},
+			{Item: "B", Weight: 1},
+			{Item: "C", Weight: 2},
+			{Item: "D", Weight: 1},
+		}
+		lb := newBuiltin(m, choices)
+		for i := 0; i < 100; i++ {
+			items := lb.SelectN(3, strconv.Itoa(i))
+			if len(items) != 3 {
+				t.Fatalf("%s expected 3 items, actual %v", m, items)
+			}
+			if items[0] == items[1] || items[0] == items[2] || items[1] == items[2] {
+				t.Fatalf("%s expected distinct items, actual %v", m, items)
+			}
+		}
+		if items := lb.SelectN(10, "k"); len(items) != 4 {
+			t.Fatalf("%s expected 4 items, actual %v", m, items)
+		}
+		if lb.SelectN(0, "k") != nil {
+			t.Fatalf("%s expected nil", m)
+		}
+
+		choices[0].ejected = 1
+		for i := 0; i < 100; i++ {
+			items := lb.SelectN(4, strconv.Itoa(i))
+			if len(items) != 3 {
+				t.Fatalf("%s expected 3 items, actual %v", m, items)
+			}
+			for _, item := range items {
+				if item == "A" {
+					t.Fatalf("%s selected an ejected item", m)
+				}
+			}
+		}
+		choices[1].ejected, choices[2].ejected, choices[3].ejected = 1, 1, 1
+		if items := lb.SelectN(2, "k"); len(items) != 0 {
+			t.Fatalf("%s expected no items, actual %v", m, items)
+		}
+
+		if newBuiltin(m, nil).SelectN(2, "k") != nil {
+			t.Fatalf("%s expected nil", m)
+		}
+	}
+}
+
+func TestSelectN_Rotation(t *testing.T) {
+	lb := NewRoundRobin(NewChoicesSlice([]string{"A", "B", "C", "D"})...)
+	lb.Select()
+	items := lb.SelectN(2)
+	if items[0] != "B" || items[1] != "C" || lb.Select() != "D" {
+		t.Fatalf("rr expected the next items, actual %v", items)
+	}
+
+	// the same as selecting one by one, skipping repeats
+	weights := map[string]int{"A": 5, "B": 1, "C": 2}
+	a, b := NewSmoothWeightedRoundRobin(), NewSmoothWeightedRoundRobin()
+	a.Update(NewChoicesMap(weights))
+	b.Update(NewChoicesMap(weights))
+	items = a.SelectN(3)
+	var want []interface{}
+	for len(want) < 3 {
+		item := b.Select()
+		if !containsItem(want, item) {
+			want = append(want, item)
+		}
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Fatalf("swrr expected %v, actual %v", want, items)
+		}
+	}
+}
+
+func TestSelectN_Skewed(t *testing.T) {
+	choices := []*Choice{
+		{Item: "A", Weight: 100000},
+		{Item: "B", Weight: 1},
+		{Item: "C", Weight: 1},
+	}
+	for _, lb := range []builtin{NewWeightedRoundRobin(choices...), NewSmoothWeightedRoundRobin(choices...)} {
+		items := lb.SelectN(2)
+		if len(items) != 2 || items[0] != "A" || items[1] == "A" {
+			t.Fatalf("%s expected A and a fallback, actual %v", lb.Name(), items)
+		}
+		// the rotation is advanced by at most n * len(items) selections
+		n := 0
+		for lb.Select() == "A" {
+			n++
+		}
+		if n < 1000 {
+			t.Fatalf("%s expected the rotation to be kept, actual %d", lb.Name(), n)
+		}
+	}
+}
+
+func containsItem(items []interface{}, item interface{}) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSelectN_Hash(t *testing.T) {
+	choices := NewChoicesSlice([]string{"A", "B", "C", "D", "E"})
+	lb := NewConsistentHash(choices...)
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		items := lb.SelectN(3, key)
+		if items[0] != lb.Select(key) {
+			t.Fatal("hash expected the owner first")
+		}
+		again := lb.SelectN(3, key)
+		for j := range items {
+			if items[j] != again[j] {
+				t.Fatal("hash expected the same items")
+			}
+		}
+
+		// the fallback of an ejected owner is the next replica
+		owner := choices[0]
+		for _, c := range choices {
+			if c.Item == items[0] {
+				owner = c
+			}
+		}
+		owner.ejected = 1
+		if item := lb.Select(key); item != items[1] {
+			t.Fatalf("hash expected %v, actual %v", items[1], item)
+		}
+		owner.ejected = 0
+	}
+}
+
+func TestSelectN_Weighted(t *testing.T) {
+	choices := []*Choice{
+		{Item: "A", Weight: 8},
+		{Item: "B", Weight: 1},
+		{Item: "C", Weight: 1},
+	}
+	for _, lb := range []builtin{
+		NewWeightedRandWithSource(NewSource(1), choices...),
+		NewWeightedRandAliasWithSource(NewSource(1), choices...),
+	} {
+		// P(A in a sample of 2) = 1 - 2 * 0.1 * 1/9
+		n := 0
+		for i := 0; i < 10000; i++ {
+			items := lb.SelectN(2)
+			if containsItem(items, "A") {
+				n++
+			}
+		}
+		if n < 9700 || n > 9850 {
+			t.Fatalf("%s sample wrong: %d", lb.Name(), n)
+		}
+	}
+}
+
+func TestCluster_SelectN(t *testing.T) {
+	choices := NewChoicesSlice([]string{"A", "B", "C"})
+	c := NewCluster(NewRoundRobin(), choices...)
+	var selected int
+	c.Observe(&ObserverFuncs{Select: func(interface{}, []string) { selected++ }})
+
+	if items := c.SelectN(2); len(items) != 2 || selected != 2 {
+		t.Fatalf("cluster SelectN wrong: %v", items)
+	}
+	s := c.Stats()
+	if s.Selections != 2 || s.Choices[0].Selections != 1 || s.Choices[2].Selections != 0 {
+		t.Fatalf("cluster SelectN stats wrong: %+v", s)
+	}
+
+	c = NewCluster(New(firstMode, nil), choices...)
+	if items := c.SelectN(2); len(items) != 2 || items[0] != "A" {
+		t.Fatalf("cluster SelectN wrong: %v", items)
+	}
+}
@@ -0,0 +1,53 @@
+package balancer
+
+import (
+	"sync/atomic"
+
+	"github.com/shibingli/load-balancer/utils"
+)
+
+// Source is a source of random numbers for Random and WeightedRand.
+type Source interface {
+	// Uint32n returns a random number in [0, n), n > 0.
+	Uint32n(n uint32) uint32
+}
+
+// runtimeSource is the default Source, backed by the runtime generator, it cannot be seeded.
+type runtimeSource struct{}
+
+func (runtimeSource) Uint32n(n uint32) uint32 {
+	return utils.FastRandn(n)
+}
+
+// splitMix64 is a seeded Source, goroutine-safe.
+// Ref: https://prng.di.unimi.it/splitmix64.c
+type splitMix64 struct {
+	state uint64
+}
+
+// NewSource create a goroutine-safe Source seeded with seed, the same seed gives the same sequence
+// as long as it is used by one goroutine, e.g. for reproducible tests:
+//
+//	lb := balancer.NewRandomWithSource(balancer.NewSource(1), choices...)
+func NewSource(seed uint64) Source {
+	return &splitMix64{state: seed}
+}
+
+func (s *splitMix64) Uint64() uint64 {
+	z := atomic.AddUint64(&s.state, 0x9e3779b97f4a7c15)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix64) Uint32n(n uint32) uint32 {
+	// See https://lemire.me/blog/2016/06/27/a-fast-alternative-to-the-modulo-reduction/
+	return uint32((s.Uint64() >> 32) * uint64(n) >> 32)
+}
+
+func sourceOrDefault(src Source) Source {
+	if src == nil {
+		return runtimeSource{}
+	}
+	return src
+}
@@ -0,0 +1,72 @@
+package balancer
+
+import (
+	"testing"
+)
+
+func TestNewSource(t *testing.T) {
+	a, b := NewSource(42), NewSource(42)
+	for i := 0; i < 1000; i++ {
+		x, y := a.Uint32n(10), b.Uint32n(10)
+		if x != y {
+			t.Fatal("source expected the same sequence for the same seed")
+		}
+		if x >= 10 {
+			t.Fatalf("source out of range: %d", x)
+		}
+	}
+
+	count := make([]int, 4)
+	src := NewSource(1)
+	for i := 0; i < 4000; i++ {
+		count[src.Uint32n(4)]++
+	}
+	for i, n := range count {
+		if n < 800 || n > 1200 {
+			t.Fatalf("source wrong: %d: %d", i, n)
+		}
+	}
+
+	if NewSource(1).Uint32n(1<<31) == NewSource(2).Uint32n(1<<31) {
+		t.Fatal("source expected different sequences for different seeds")
+	}
+}
+
+func TestRuntimeSource(t *testing.T) {
+	src := sourceOrDefault(nil)
+	for i := 0; i < 1000; i++ {
+		if n := src.Uint32n(3); n >= 3 {
+			t.Fatalf("runtime source out of range: %d", n)
+		}
+	}
+}
+
+func TestWithSource_Reproducible(t *testing.T) {
+	choices := []*Choice{
+		{Item: "A", Weight: 5},
+		{Item: "B", Weight: 3},
+		{Item: "C", Weight: 1},
+	}
+	for _, newLB := range []func(Source) Balancer{
+		func(src Source) Balancer { return NewRandomWithSource(src, choices...) },
+		func(src Source) Balancer { return NewWeightedRandWithSource(src, choices...) },
+	} {
+		a, b := newLB(NewSource(7)), newLB(NewSource(7))
+		for i := 0; i < 1000; i++ {
+			if x, y := a.Select(), b.Select(); x != y {
+				t.Fatalf("%s expected the same selections, actual %v and %v", a.Name(), x, y)
+			}
+		}
+	}
+
+	// the slow path draws from the source too
+	choices[0].ejected = 1
+	defer func() { choices[0].ejected = 0 }()
+	a, b := NewWeightedRandWithSource(NewSource(7), choices...), NewWeightedRandWithSource(NewSource(7), choices...)
+	for i := 0; i < 1000; i++ {
+		x, y := a.Select(), b.Select()
+		if x != y || x == "A" {
+			t.Fatalf("wr expected the same selections, actual %v and %v", x, y)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package balancer
+
+import "sync"
+
+// SpreadSelector is implemented by the hash balancers, to place the replicas of a key
+// in distinct failure domains.
+//
+//	if s, ok := lb.(balancer.SpreadSelector); ok {
+//		replicas = s.SelectSpread(3, "zone", key)
+//	}
+type SpreadSelector interface {
+	// SelectSpread gets up to n distinct items of the key in the order of SelectN,
+	// skipping the items in a value of the label already taken, e.g. the same "zone".
+	// With fewer values than n, the items are spread over the values as evenly as possible.
+	// Items without the label share the empty value.
+	SelectSpread(n int, label string, key ...string) []interface{}
+}
+
+// spreadSelector is implemented by the built-in balancers of SpreadSelector, Cluster uses it
+// to record the selections of each choice.
+type spreadSelector interface {
+	selectSpread(n int, label string, key []string) []*Choice
+}
+
+// labelCounts caches the number of items of each value of the labels, built on first use.
+// The balancers replace it on Update, selections may use it concurrently.
+type labelCounts struct {
+	mu     sync.Mutex
+	counts map[string]map[string]int
+}
+
+// get returns the number of the items of each value of the label.
+func (l *labelCounts) get(label string, items []*Choice) map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if counts, ok := l.counts[label]; ok {
+		return counts
+	}
+	counts := make(map[string]int)
+	for _, c := range items {
+		counts[c.Labels[label]]++
+	}
+	if l.counts == nil {
+		l.counts = make(map[string]map[string]int)
+	}
+	l.counts[label] = counts
+	return counts
+}
+
+// spread picks up to n candidates in distinct values of the label.
+// candidates(k) must return the first up to k candidates of the key, the same prefix for any k,
+// it is called with growing k until the picks cannot change any more or all count candidates are known.
+// Without n values, the candidates are taken in rounds: the candidates of the values
+// not yet taken, then of the values taken once, and so on.
+// total is the number of items of each value of the label, every candidate is one of them.
+func spread(n int, label string, count int, total map[string]int, candidates func(k int) []*Choice) []*Choice {
+	if n <= 0 || count == 0 {
+		return nil
+	}
+
+	for k := 2 * n; ; k *= 2 {
+		if k > count {
+			k = count
+		}
+		cands := candidates(k)
+		choices, rounds := spreadRounds(n, label, cands)
+		if len(cands) < k || k == count || len(choices) == n && rounds == 1 {
+			return choices
+		}
+
+		// more candidates only change the picks if they add a value, or a candidate to a value
+		// that ran out before the last round
+		seen := make(map[string]int)
+		for _, c := range cands {
+			seen[c.Labels[label]]++
+		}
+		done := len(seen) == len(total)
+		for v, m := range seen {
+			if m < rounds && m < total[v] {
+				done = false
+			}
+		}
+		if done {
+			return choices
+		}
+	}
+}
+
+// spreadRounds takes up to n candidates in rounds over the values of the label,
+// rounds is the number of rounds taken.
+func spreadRounds(n int, label string, cands []*Choice) (choices []*Choice, rounds int) {
+	if n > len(cands) {
+		n = len(cands)
+	}
+	choices = make([]*Choice, 0, n)
+	picked := make([]bool, len(cands))
+	taken := make(map[string]int)
+	for ; len(choices) < n; rounds++ {
+		for i, c := range cands {
+			if picked[i] || taken[c.Labels[label]] != rounds {
+				continue
+			}
+			picked[i] = true
+			taken[c.Labels[label]]++
+			if choices = append(choices, c); len(choices) == n {
+				break
+			}
+		}
+	}
+	return choices, rounds
+}
@@ -0,0 +1,152 @@
+package balancer
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// genZones returns n choices over the zones, round-robin.
+func genZones(n, zones int) []*Choice {
+	choices := make([]*Choice, n)
+	for i := range choices {
+		choices[i] = &Choice{
+			Item:   "node-" + strconv.Itoa(i),
+			Weight: 1,
+			Labels: map[string]string{"zone": fmt.Sprintf("z%d", i%zones)},
+		}
+	}
+	return choices
+}
+
+func TestSelectSpread(t *testing.T) {
+	for _, m := range []Mode{ConsistentHash, MultiProbeConsistentHash, AnchorHash, RendezvousHash} {
+		choices := genZones(30, 3)
+		lb := New(m, choices).(SpreadSelector)
+		zone := make(map[interface{}]string)
+		for _, c := range choices {
+			zone[c.Item] = c.Labels["zone"]
+		}
+
+		for i := 0; i < 200; i++ {
+			key := strconv.Itoa(i)
+			items := lb.SelectSpread(3, "zone", key)
+			if len(items) != 3 {
+				t.Fatalf("%s expected 3 items, actual %v", m, items)
+			}
+			if zone[items[0]] == zone[items[1]] || zone[items[0]] == zone[items[2]] || zone[items[1]] == zone[items[2]] {
+				t.Fatalf("%s expected distinct zones, actual %v", m, items)
+			}
+			// the owner comes first
+			if items[0] != New(m, choices).Select(key) {
+				t.Fatalf("%s expected the owner first", m)
+			}
+		}
+
+		// 3 zones for 5 replicas: 2, 2, 1
+		for i := 0; i < 200; i++ {
+			items := lb.SelectSpread(5, "zone", strconv.Itoa(i))
+			count := make(map[string]int)
+			for _, item := range items {
+				count[zone[item]]++
+			}
+			if len(items) != 5 || len(count) != 3 {
+				t.Fatalf("%s spread wrong: %v", m, items)
+			}
+			for _, n := range count {
+				if n > 2 {
+					t.Fatalf("%s spread wrong: %v", m, items)
+				}
+			}
+			if zone[items[0]] == zone[items[1]] || zone[items[0]] == zone[items[2]] || zone[items[1]] == zone[items[2]] {
+				t.Fatalf("%s expected the distinct zones first, actual %v", m, items)
+			}
+		}
+
+		// a zone down
+		for _, c := range choices {
+			if c.Labels["zone"] == "z1" {
+				c.ejected = 1
+			}
+		}
+		items := lb.SelectSpread(3, "zone", "k")
+		if len(items) != 3 || zone[items[0]] == zone[items[1]] {
+			t.Fatalf("%s spread wrong: %v", m, items)
+		}
+		for _, item := range items {
+			if zone[item] == "z1" {
+				t.Fatalf("%s selected an ejected item", m)
+			}
+		}
+
+		// no label: all in one zone
+		if items := lb.SelectSpread(3, "rack", "k"); len(items) != 3 {
+			t.Fatalf("%s spread wrong: %v", m, items)
+		}
+		if items := lb.SelectSpread(100, "zone", "k"); len(items) != 20 {
+			t.Fatalf("%s expected 20 items, actual %d", m, len(items))
+		}
+		if New(m, nil).(SpreadSelector).SelectSpread(3, "zone", "k") != nil {
+			t.Fatalf("%s expected nil", m)
+		}
+	}
+}
+
+func TestCluster_SelectSpread(t *testing.T) {
+	choices := genZones(6, 3)
+	c := NewCluster(NewConsistentHash(), choices...)
+	items := c.SelectSpread(3, "zone", "k")
+	if len(items) != 3 {
+		t.Fatalf("spread expected 3 items, actual %v", items)
+	}
+	var total uint64
+	for _, s := range c.Stats().Choices {
+		total += s.Selections
+	}
+	if total != 3 {
+		t.Fatalf("spread expected 3 selections, actual %d", total)
+	}
+
+	if NewCluster(NewRoundRobin(), choices...).SelectSpread(3, "zone", "k") != nil {
+		t.Fatal("spread expected nil")
+	}
+}
+
+func TestSelectSpread_Prefix(t *testing.T) {
+	// fewer zones than replicas in a large fleet
+	choices := genZones(100000, 3)
+	total := map[string]int{}
+	for _, c := range choices {
+		total[c.Labels["zone"]]++
+	}
+	max := 0
+	candidates := func(k int) []*Choice {
+		if k > max {
+			max = k
+		}
+		return choices[:k]
+	}
+	for _, n := range []int{1, 3, 5, 10} {
+		max = 0
+		items := spread(n, "zone", len(choices), total, candidates)
+		want, _ := spreadRounds(n, "zone", choices)
+		if len(items) != len(want) {
+			t.Fatalf("spread of %d expected %d items, actual %d", n, len(want), len(items))
+		}
+		for i := range items {
+			if items[i] != want[i] {
+				t.Fatalf("spread of %d expected the picks of all candidates", n)
+			}
+		}
+		if max > 4*n {
+			t.Fatalf("spread of %d expected a short prefix, actual %d candidates", n, max)
+		}
+	}
+
+	// a zone only found late
+	choices[len(choices)-1].Labels = map[string]string{"zone": "z9"}
+	total["z9"], total["z0"] = 1, total["z0"]-1
+	if items := spread(5, "zone", len(choices), total, candidates); items[3] != choices[len(choices)-1] {
+		t.Fatalf("spread expected the item of the zone found last, actual %v", items)
+	}
+}
@@ -0,0 +1,140 @@
+package balancer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the selection latency histogram buckets.
+var latencyBounds = [...]time.Duration{
+	50 * time.Nanosecond,
+	100 * time.Nanosecond,
+	250 * time.Nanosecond,
+	500 * time.Nanosecond,
+	time.Microsecond,
+	2500 * time.Nanosecond,
+	5 * time.Microsecond,
+	10 * time.Microsecond,
+	25 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+}
+
+// Stats is a snapshot of a Cluster.
+type Stats struct {
+	// Name of the balancer
+	Name string
+
+	// Selections is the number of successful selections.
+	Selections uint64
+
+	// Errors is the number of selections that returned an error.
+	Errors uint64
+
+	// Latency of the selections
+	Latency Histogram
+
+	// Choices in the order of the last Update
+	Choices []ChoiceStats
+}
+
+// ChoiceStats is a snapshot of one choice of a Cluster.
+type ChoiceStats struct {
+	Item interface{}
+
+	// Key identifies the choice, see Choice.Key.
+	Key string
+
+	Weight        int
+	CurrentWeight int
+	Labels        map[string]string
+
+	// EffectiveWeight is the Weight, or 0 while ejected.
+	EffectiveWeight int
+
+	// Selections is the number of times the choice has been selected.
+	Selections uint64
+
+	Inflight  int
+	Limit     int
+	Ejected   bool
+	Available bool
+}
+
+// Histogram of durations, Counts[i] is the number of observations <= Bounds[i],
+// above the bounds of previous buckets. The last count is for observations above all bounds.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+type histogram struct {
+	counts [len(latencyBounds) + 1]uint64
+	count  uint64
+	sum    int64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+func (h *histogram) snapshot() Histogram {
+	s := Histogram{
+		Bounds: append([]time.Duration(nil), latencyBounds[:]...),
+		Counts: make([]uint64, len(h.counts)),
+		Count:  atomic.LoadUint64(&h.count),
+		Sum:    time.Duration(atomic.LoadInt64(&h.sum)),
+	}
+	for i := range h.counts {
+		s.Counts[i] = atomic.LoadUint64(&h.counts[i])
+	}
+	return s
+}
+
+// Stats returns a snapshot of the selection counts and the state of every choice.
+func (c *Cluster) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Stats{
+		Name:       c.lb.Name(),
+		Selections: atomic.LoadUint64(&c.selections),
+		Errors:     atomic.LoadUint64(&c.errors),
+		Latency:    c.latency.snapshot(),
+		Choices:    make([]ChoiceStats, 0, len(c.choices)),
+	}
+	for _, choice := range c.choices {
+		if choice == nil {
+			continue
+		}
+		cs := ChoiceStats{
+			Item:            choice.Item,
+			Key:             choice.Key(),
+			Weight:          choice.Weight,
+			CurrentWeight:   choice.CurrentWeight,
+			Labels:          choice.Labels,
+			EffectiveWeight: choice.Weight,
+			Inflight:        choice.Inflight(),
+			Limit:           choice.Limit(),
+			Ejected:         choice.Ejected(),
+			Available:       choice.Available(),
+		}
+		if cs.Ejected {
+			cs.EffectiveWeight = 0
+		}
+		if st := c.states[choice]; st != nil {
+			cs.Selections = atomic.LoadUint64(&st.selections)
+		}
+		s.Choices = append(s.Choices, cs)
+	}
+	return s
+}
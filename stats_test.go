@@ -0,0 +1,77 @@
+package balancer
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestCluster_Stats(t *testing.T) {
+	choices := []*Choice{
+		{Item: "A", Weight: 5},
+		{Item: "B", Weight: 1},
+		{Item: "C", Weight: 0},
+	}
+	c := NewCluster(NewSmoothWeightedRoundRobin(), choices...)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 300; j++ {
+				c.Select()
+			}
+		}()
+	}
+	wg.Wait()
+
+	l, err := c.SelectContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Eject(choices[1], "test")
+
+	s := c.Stats()
+	if s.Name != "SmoothWeightedRoundRobin" || s.Selections != 6001 || s.Errors != 0 {
+		t.Fatalf("stats wrong: %+v", s)
+	}
+	if len(s.Choices) != 3 {
+		t.Fatal("stats choices wrong")
+	}
+	a, b, cc := s.Choices[0], s.Choices[1], s.Choices[2]
+	if a.Item != "A" || a.Selections+b.Selections != 6001 || a.Selections < 5000 || cc.Selections != 0 {
+		t.Fatalf("stats selections wrong: %+v", s.Choices)
+	}
+	if a.Inflight+b.Inflight != 1 || a.EffectiveWeight != 5 {
+		t.Fatalf("stats choice wrong: %+v", a)
+	}
+	if !b.Ejected || b.Available || b.EffectiveWeight != 0 || b.Weight != 1 {
+		t.Fatalf("stats ejection wrong: %+v", b)
+	}
+	l.Done(nil)
+
+	if s.Latency.Count != 6001 || len(s.Latency.Counts) != len(s.Latency.Bounds)+1 {
+		t.Fatal("stats latency wrong")
+	}
+	var n uint64
+	for _, v := range s.Latency.Counts {
+		n += v
+	}
+	if n != s.Latency.Count || s.Latency.Sum <= 0 {
+		t.Fatal("stats latency wrong")
+	}
+
+	c.Eject(choices[0], "test")
+	c.Select()
+	if s := c.Stats(); s.Errors != 1 {
+		t.Fatal("stats errors wrong")
+	}
+
+	// counts are kept across updates
+	c.Recover(choices[0])
+	c.Update(choices[:1])
+	if s := c.Stats(); len(s.Choices) != 1 || s.Choices[0].Selections != a.Selections {
+		t.Fatal("stats update wrong")
+	}
+}
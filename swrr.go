@@ -3,8 +3,13 @@ package balancer
 // Smooth weighted round-robin balancing
 // Ref: https://github.com/phusion/nginx/commit/27e94984486058d73157038f7950a0a36ecc6e35
 type swrr struct {
+	all   []*Choice
 	items []*Choice
 	count int
+	total int
+
+	sched     *schedule
+	schedSize int
 }
 
 func NewSmoothWeightedRoundRobin(choices ...*Choice) (lb *swrr) {
@@ -13,16 +18,62 @@ func NewSmoothWeightedRoundRobin(choices ...*Choice) (lb *swrr) {
 	return
 }
 
+// NewSmoothWeightedRoundRobinWithSchedule create a SmoothWeightedRoundRobin balancer that precomputes
+// its cycle of up to size selections on Update, e.g. DefaultScheduleSize.
+// Selection then takes constant time, is goroutine-safe and leaves Choice.CurrentWeight untouched,
+// longer cycles fall back to the regular algorithm, see Scheduled.
+// Precomputing takes O(cycle * choices) time, more than about 16M steps fall back as well.
+func NewSmoothWeightedRoundRobinWithSchedule(size int, choices ...*Choice) (lb *swrr) {
+	lb = &swrr{schedSize: size}
+	lb.Update(choices)
+	return
+}
+
 func (b *swrr) Select(_ ...string) (item interface{}) {
+	item, _ = b.SelectE()
+	return
+}
+
+func (b *swrr) SelectE(_ ...string) (interface{}, error) {
+	c, err := b.SelectChoice()
+	if err != nil {
+		return nil, err
+	}
+	return c.Item, nil
+}
+
+func (b *swrr) SelectChoice(_ ...string) (*Choice, error) {
 	switch b.count {
 	case 0:
-		item = nil
+		return nil, noChoice(b.total)
 	case 1:
-		item = b.items[0].Item
+		if !b.items[0].Available() {
+			return nil, unavailable(b.items)
+		}
+		return b.items[0], nil
 	default:
-		item = b.chooseNext().Item
+		var c *Choice
+		if b.sched != nil {
+			c = b.sched.next(b.items)
+		} else {
+			c = b.chooseNext()
+		}
+		if c == nil {
+			return nil, unavailable(b.items)
+		}
+		return c, nil
 	}
-	return
+}
+
+func (b *swrr) SelectN(n int, key ...string) []interface{} {
+	return choiceItems(b.selectN(n, key))
+}
+
+func (b *swrr) selectN(n int, _ []string) []*Choice {
+	if b.sched != nil {
+		return nextDistinct(n, b.items, func() *Choice { return b.sched.next(b.items) })
+	}
+	return nextDistinct(n, b.items, b.chooseNext)
 }
 
 func (b *swrr) chooseNext() (choice *Choice) {
@@ -32,6 +83,9 @@ func (b *swrr) chooseNext() (choice *Choice) {
 		if c == nil {
 			return nil
 		}
+		if !c.Available() {
+			continue
+		}
 
 		total += c.Weight
 		c.CurrentWeight += c.Weight
@@ -50,11 +104,33 @@ func (b *swrr) chooseNext() (choice *Choice) {
 	return choice
 }
 
+// Scheduled reports whether the selections come from a precomputed schedule.
+func (b *swrr) Scheduled() bool {
+	return b.sched != nil
+}
+
 func (b *swrr) Name() string {
 	return "SmoothWeightedRoundRobin"
 }
 
+// Choices returns all items, including those with a weight less than 1.
+func (b *swrr) Choices() []*Choice {
+	return b.all
+}
+
 func (b *swrr) Update(choices []*Choice) bool {
-	b.items, b.count = cleanWeight(choices)
-	return b.count > 0
+	return b.UpdateE(choices) == nil
+}
+
+func (b *swrr) UpdateE(choices []*Choice) error {
+	b.all, _ = cleanChoices(choices)
+	b.items, b.count, b.total = cleanWeight(b.all)
+	b.sched = nil
+	if b.schedSize > 0 {
+		b.sched = newSWRRSchedule(b.items, weightsGCD(b.items), b.schedSize)
+	}
+	if b.count == 0 {
+		return noChoice(b.total)
+	}
+	return nil
 }
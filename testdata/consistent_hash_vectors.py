@@ -0,0 +1,91 @@
+#!/usr/bin/env python3
+"""Reference implementation of the ConsistentHash placement, generating consistent_hash_vectors.json.
+
+    python3 testdata/consistent_hash_vectors.py > testdata/consistent_hash_vectors.json
+
+The placement only depends on the set of the node identities, the Key of the choices:
+its ID if set, else the item, e.g. the address.
+
+1. the identities are sorted by their UTF-8 bytes, e.g. not by UTF-16 code units in Java;
+2. the key is hashed with 64-bit FNV-1a over its UTF-8 bytes, the parts of a key are concatenated;
+3. the owner is sorted[jump(hash, len(sorted))], with the jump consistent hash of Lamping and Veach
+   computed with IEEE 754 doubles;
+4. the replicas are the owner followed by the distinct owners of the probes
+   hash = hash * 0x9e3779b97f4a7c15 + 1 (mod 2^64), at most 4 * len(sorted) probes,
+   then the remaining nodes in sorted order.
+"""
+
+import json
+import random
+import sys
+
+MASK = (1 << 64) - 1
+
+
+def fnv1a64(data):
+    h = 14695981039346656037
+    for b in data:
+        h ^= b
+        h = (h * 1099511628211) & MASK
+    return h
+
+
+def jump(key, buckets):
+    b, j = -1, 0
+    while j < buckets:
+        b = j
+        key = (key * 2862933555777941757 + 1) & MASK
+        j = int(float(b + 1) * (float(1 << 31) / float((key >> 33) + 1)))
+    return b
+
+
+def replicas(nodes, key, n):
+    ids = sorted(nodes, key=lambda s: s.encode("utf-8"))
+    n = min(n, len(ids))
+    h = fnv1a64(key.encode("utf-8"))
+    out = [ids[jump(h, len(ids))]]
+    for _ in range(4 * len(ids)):
+        if len(out) == n:
+            break
+        h = (h * 0x9E3779B97F4A7C15 + 1) & MASK
+        c = ids[jump(h, len(ids))]
+        if c not in out:
+            out.append(c)
+    for c in ids:
+        if len(out) == n:
+            break
+        if c not in out:
+            out.append(c)
+    return out
+
+
+def main():
+    rnd = random.Random(49)
+    node_sets = [
+        ["10.0.0.3:8080", "10.0.0.1:8080", "10.0.0.2:8080"],
+        ["cache-%d" % i for i in range(10)],
+        ["node-c", "nœud-b", "节点-a", "Node-D", "node-\U0001f642"],
+        ["10.1.%d.%d:11211" % (i // 16, i % 16) for i in range(100)],
+    ]
+    keys = ["", "a", "user:1", "user:42", "session-\U0001f642", "Straße"]
+    keys += ["key-%d" % i for i in range(20)]
+
+    cases = []
+    for nodes in node_sets:
+        nodes = nodes[:]
+        rnd.shuffle(nodes)
+        cases.append({
+            "nodes": nodes,
+            "keys": [{"key": k, "owner": replicas(nodes, k, 1)[0], "replicas": replicas(nodes, k, 3)} for k in keys],
+        })
+
+    json.dump({
+        "algorithm": "jump consistent hash over the node identities sorted by UTF-8 bytes, "
+                     "keys hashed with 64-bit FNV-1a, replicas from the probes hash*0x9e3779b97f4a7c15+1",
+        "cases": cases,
+    }, sys.stdout, ensure_ascii=False, indent=2)
+    print()
+
+
+if __name__ == "__main__":
+    main()
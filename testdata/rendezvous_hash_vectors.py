@@ -0,0 +1,78 @@
+#!/usr/bin/env python3
+"""Reference implementation of the RendezvousHash placement, generating rendezvous_hash_vectors.json.
+
+    python3 testdata/rendezvous_hash_vectors.py > testdata/rendezvous_hash_vectors.json
+
+The placement only depends on the set of the node identities, the Key of the choices:
+its ID if set, else the item, e.g. the address. It is rendezvous (highest random weight) hashing.
+
+1. the key and the identities are hashed with 64-bit FNV-1a over their UTF-8 bytes,
+   the parts of a key are concatenated;
+2. the score of a node is mix64(hash(key) ^ mix64(hash(identity))), with mix64 the 64-bit finalizer
+   of MurmurHash3 (fmix64);
+3. the owner is the node of the highest score, the replicas are the nodes in decreasing order of score;
+4. equal scores are ordered by the identities sorted by their UTF-8 bytes, e.g. not by UTF-16 code units in Java.
+"""
+
+import json
+import random
+import sys
+
+MASK = (1 << 64) - 1
+
+
+def fnv1a64(data):
+    h = 14695981039346656037
+    for b in data:
+        h ^= b
+        h = (h * 1099511628211) & MASK
+    return h
+
+
+def mix64(k):
+    k ^= k >> 33
+    k = (k * 0xFF51AFD7ED558CCD) & MASK
+    k ^= k >> 33
+    k = (k * 0xC4CEB9FE1A85EC53) & MASK
+    k ^= k >> 33
+    return k
+
+
+def replicas(nodes, key, n):
+    ids = sorted(nodes, key=lambda s: s.encode("utf-8"))
+    h = fnv1a64(key.encode("utf-8"))
+    scores = {c: mix64(h ^ mix64(fnv1a64(c.encode("utf-8")))) for c in ids}
+    # a stable sort keeps the identity order of equal scores
+    return sorted(ids, key=lambda c: -scores[c])[:n]
+
+
+def main():
+    rnd = random.Random(49)
+    node_sets = [
+        ["10.0.0.3:8080", "10.0.0.1:8080", "10.0.0.2:8080"],
+        ["cache-%d" % i for i in range(10)],
+        ["node-c", "nœud-b", "节点-a", "Node-D", "node-\U0001f642"],
+        ["10.1.%d.%d:11211" % (i // 16, i % 16) for i in range(100)],
+    ]
+    keys = ["", "a", "user:1", "user:42", "session-\U0001f642", "Straße"]
+    keys += ["key-%d" % i for i in range(20)]
+
+    cases = []
+    for nodes in node_sets:
+        nodes = nodes[:]
+        rnd.shuffle(nodes)
+        cases.append({
+            "nodes": nodes,
+            "keys": [{"key": k, "owner": replicas(nodes, k, 1)[0], "replicas": replicas(nodes, k, 3)} for k in keys],
+        })
+
+    json.dump({
+        "algorithm": "rendezvous hashing, score mix64(fnv1a64(key) ^ mix64(fnv1a64(node))) with mix64 the "
+                     "fmix64 of MurmurHash3, replicas in decreasing order of score",
+        "cases": cases,
+    }, sys.stdout, ensure_ascii=False, indent=2)
+    print()
+
+
+if __name__ == "__main__":
+    main()
@@ -1,18 +1,16 @@
 package utils
 
 import (
-	"reflect"
 	"unsafe"
 )
 
-// S2B StringToBytes
-func S2B(s string) (b []byte) {
-	sh := *(*reflect.StringHeader)(unsafe.Pointer(&s))
-	bh := (*reflect.SliceHeader)(unsafe.Pointer(&b))
-	bh.Data = sh.Data
-	bh.Cap = sh.Len
-	bh.Len = sh.Len
-	return
+// S2B StringToBytes, the bytes must not be modified.
+// It is built without reflect.StringHeader, which go vet reports as a possible misuse.
+func S2B(s string) []byte {
+	return *(*[]byte)(unsafe.Pointer(&struct {
+		string
+		Cap int
+	}{s, len(s)}))
 }
 
 // B2S BytesToString
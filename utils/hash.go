@@ -6,13 +6,32 @@ const (
 	prime64  = 1099511628211
 )
 
+// HashString hashes the concatenation of s, streaming over the parts without concatenating them.
 func HashString(s ...string) uint64 {
-	return Sum64(AddString(s...))
+	var h uint64 = offset64
+	for _, v := range s {
+		h = AddSum64(h, v)
+	}
+	return h
 }
 
 // Sum64 similar to fnv.New64a().Sum64(), but faster.
 func Sum64(s string) uint64 {
+	return AddSum64(offset64, s)
+}
+
+// SumBytes64 is Sum64 of a []byte.
+func SumBytes64(b []byte) uint64 {
 	var h uint64 = offset64
+	for i := 0; i < len(b); i++ {
+		h ^= uint64(b[i])
+		h *= prime64
+	}
+	return h
+}
+
+// AddSum64 continues the hash h with s, e.g. h = AddSum64(Sum64(a), b) is Sum64(a + b).
+func AddSum64(h uint64, s string) uint64 {
 	for i := 0; i < len(s); i++ {
 		h ^= uint64(s[i])
 		h *= prime64
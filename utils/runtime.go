@@ -1,3 +1,5 @@
+//go:build !go1.22
+
 package utils
 
 import (
@@ -6,5 +8,6 @@ import (
 
 // FastRandn similar to fastrand() % n, but faster.
 // See https://lemire.me/blog/2016/06/27/a-fast-alternative-to-the-modulo-reduction/
+//
 //go:linkname FastRandn runtime.fastrandn
 func FastRandn(n uint32) uint32
@@ -0,0 +1,13 @@
+//go:build go1.22
+
+package utils
+
+import (
+	"math/rand/v2"
+)
+
+// FastRandn returns a random number in [0, n) from the per-thread runtime generator,
+// without linking to runtime internals.
+func FastRandn(n uint32) uint32 {
+	return rand.Uint32N(n)
+}
@@ -0,0 +1,252 @@
+package balancer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shibingli/load-balancer/utils"
+)
+
+// DefaultWatchInterval is the polling interval used when none is given.
+const DefaultWatchInterval = 5 * time.Second
+
+// fileConfig is the JSON layout of a backend file, e.g.
+//
+//	{
+//	  "items": [
+//	    {"item": "10.0.0.1:80", "weight": 5, "labels": {"zone": "a"}},
+//	    {"item": "10.0.0.2:80", "weight": 0},
+//	    {"item": "10.0.0.3:80", "id": "cache-3"}
+//	  ]
+//	}
+type fileConfig struct {
+	Items []fileChoice `json:"items"`
+}
+
+type fileChoice struct {
+	Item   string            `json:"item"`
+	ID     string            `json:"id"`
+	Weight *int              `json:"weight"`
+	Labels map[string]string `json:"labels"`
+}
+
+// ParseChoices decodes and validates a JSON backend list.
+// Omitted weights default to 1, a weight of 0 keeps the item but never selects it.
+func ParseChoices(data []byte) ([]*Choice, error) {
+	var cfg fileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("balancer: invalid config: %w", err)
+	}
+	if len(cfg.Items) == 0 {
+		return nil, errors.New("balancer: invalid config: no items")
+	}
+
+	n := 0
+	seen := make(map[string]struct{}, len(cfg.Items))
+	choices := make([]*Choice, 0, len(cfg.Items))
+	for i, v := range cfg.Items {
+		if v.Item == "" {
+			return nil, fmt.Errorf("balancer: invalid config: items[%d]: empty item", i)
+		}
+		c := &Choice{Item: v.Item, ID: v.ID, Labels: v.Labels}
+		if _, ok := seen[c.Key()]; ok {
+			return nil, fmt.Errorf("balancer: invalid config: items[%d]: duplicate item %q", i, c.Key())
+		}
+		seen[c.Key()] = struct{}{}
+
+		w := 1
+		if v.Weight != nil {
+			w = *v.Weight
+		}
+		if w < 0 {
+			return nil, fmt.Errorf("balancer: invalid config: items[%d]: negative weight %d", i, w)
+		}
+		if w > 0 {
+			n++
+		}
+
+		c.Weight = w
+		choices = append(choices, c)
+	}
+	if n == 0 {
+		return nil, errors.New("balancer: invalid config: no item with a positive weight")
+	}
+
+	return choices, nil
+}
+
+// LoadChoices reads and validates a JSON backend list from the named file.
+func LoadChoices(name string) ([]*Choice, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseChoices(data)
+}
+
+// Watcher polls a JSON backend file and applies its choices to a Cluster when it changes.
+// A file that fails validation is rejected and the last good choices stay in place.
+//
+// Update is called from the watcher goroutine, the Cluster serializes it with the selections.
+type Watcher struct {
+	// OnError is called for every rejected file, optional. It must be set before Start.
+	OnError func(err error)
+
+	// OnUpdate is called after new choices have been applied, optional. It must be set before Start.
+	OnUpdate func(choices []*Choice)
+
+	name     string
+	c        *Cluster
+	interval time.Duration
+
+	// reload serializes the reloads, it guards the state of the file
+	reload  sync.Mutex
+	modTime time.Time
+	size    int64
+	sum     uint64
+
+	// mu guards the state read by Choices and Err, it is not held while calling out
+	mu      sync.Mutex
+	choices []*Choice
+	err     error
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewWatcher create a watcher of the named file for the cluster, e.g. NewCluster(lb) for a plain balancer,
+// which is not goroutine-safe.
+func NewWatcher(name string, c *Cluster, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	return &Watcher{
+		name:     name,
+		c:        c,
+		interval: interval,
+	}
+}
+
+// Start loads the file once and then polls it in the background.
+// The error of the first load is returned, polling goes on regardless.
+func (w *Watcher) Start() error {
+	w.mu.Lock()
+	if w.stop != nil {
+		w.mu.Unlock()
+		return nil
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	stop, done := w.stop, w.done
+	w.mu.Unlock()
+
+	err := w.Reload()
+	go w.run(stop, done)
+	return err
+}
+
+// Stop stops polling and waits for the watcher goroutine to exit.
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.stop = nil
+	w.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (w *Watcher) run(stop, done chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	defer close(done)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_ = w.Reload()
+		}
+	}
+}
+
+// Reload checks the file now and applies it if its content has changed.
+// It returns the error of a rejected file, or nil if the file is unchanged or applied.
+// OnUpdate and OnError are called without holding the lock of Choices and Err,
+// but must not call Reload.
+func (w *Watcher) Reload() error {
+	w.reload.Lock()
+	defer w.reload.Unlock()
+
+	fi, err := os.Stat(w.name)
+	if err != nil {
+		return w.fail(err)
+	}
+	if fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(w.name)
+	if err != nil {
+		return w.fail(err)
+	}
+
+	// Record the attempt first, so that a bad file is reported once and not on every tick.
+	w.modTime, w.size = fi.ModTime(), fi.Size()
+	sum := utils.Sum64(utils.B2S(data))
+	if sum == w.sum {
+		return w.Err()
+	}
+	w.sum = sum
+
+	choices, err := ParseChoices(data)
+	if err != nil {
+		return w.fail(err)
+	}
+
+	w.c.Update(choices)
+	w.mu.Lock()
+	w.choices = choices
+	w.err = nil
+	w.mu.Unlock()
+
+	if w.OnUpdate != nil {
+		w.OnUpdate(choices)
+	}
+	return nil
+}
+
+func (w *Watcher) fail(err error) error {
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+
+	if w.OnError != nil {
+		w.OnError(err)
+	}
+	return err
+}
+
+// Choices returns the last applied choices.
+func (w *Watcher) Choices() []*Choice {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.choices
+}
+
+// Err returns the error of the last reload, nil if the last good file is current.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
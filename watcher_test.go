@@ -0,0 +1,176 @@
+package balancer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseChoices(t *testing.T) {
+	choices, err := ParseChoices([]byte(`{"items": [
+		{"item": "A", "weight": 5, "labels": {"zone": "a"}},
+		{"item": "B"},
+		{"item": "C", "weight": 0},
+		{"item": "A", "id": "A2"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(choices) != 4 {
+		t.Fatalf("parse expected 4 choices, actual %d", len(choices))
+	}
+	if choices[0].Item != "A" || choices[0].Weight != 5 || choices[0].Labels["zone"] != "a" {
+		t.Fatal("parse wrong: A")
+	}
+	if choices[1].Weight != 1 || choices[2].Weight != 0 {
+		t.Fatal("parse wrong: weight")
+	}
+	if choices[3].Key() != "A2" || choices[0].Key() != "A" {
+		t.Fatal("parse wrong: id")
+	}
+
+	for _, s := range []string{
+		``,
+		`[]`,
+		`{"items": []}`,
+		`{"items": [{"item": ""}]}`,
+		`{"items": [{"item": "A"}, {"item": "A"}]}`,
+		`{"items": [{"item": "A", "id": "X"}, {"item": "B", "id": "X"}]}`,
+		`{"items": [{"item": "A", "weight": -1}]}`,
+		`{"items": [{"item": "A", "weight": 0}]}`,
+		`{"items": [{"item": "A", "wieght": 1}]}`,
+	} {
+		if _, err := ParseChoices([]byte(s)); err == nil {
+			t.Fatalf("parse expected error: %s", s)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "backends.json")
+	tick := 0
+	write := func(s string) {
+		tick++
+		if err := os.WriteFile(name, []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// make sure the change is visible even on coarse file systems
+		mt := time.Now().Add(time.Duration(tick) * time.Second)
+		if err := os.Chtimes(name, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lb := NewCluster(NewWeightedRoundRobin())
+	w := NewWatcher(name, lb, time.Hour)
+	var errs, updates int
+	// the callbacks may read the watcher
+	w.OnError = func(error) {
+		errs++
+		_ = w.Choices()
+	}
+	w.OnUpdate = func([]*Choice) {
+		updates++
+		_ = w.Err()
+	}
+
+	if err := w.Start(); err == nil {
+		t.Fatal("watcher expected error for a missing file")
+	}
+	defer w.Stop()
+
+	write(`{"items": [{"item": "A", "weight": 0}, {"item": "B", "weight": 1}]}`)
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if item := lb.Select(); item != "B" {
+		t.Fatalf("watcher expected B, actual %s", item)
+	}
+
+	// unchanged file
+	if err := w.Reload(); err != nil || updates != 1 {
+		t.Fatal("watcher reload wrong")
+	}
+
+	// invalid file keeps the last good config
+	write(`{"items": [{"item": "A", "weight": -1}]}`)
+	if err := w.Reload(); err == nil {
+		t.Fatal("watcher expected error")
+	}
+	if w.Err() == nil || errs != 2 {
+		t.Fatal("watcher error wrong")
+	}
+	if item := lb.Select(); item != "B" {
+		t.Fatalf("watcher expected B, actual %s", item)
+	}
+	if len(w.Choices()) != 2 {
+		t.Fatal("watcher choices wrong")
+	}
+
+	write(`{"items": [{"item": "C"}]}`)
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if item := lb.Select(); item != "C" {
+		t.Fatalf("watcher expected C, actual %s", item)
+	}
+	if w.Err() != nil || updates != 2 {
+		t.Fatal("watcher update wrong")
+	}
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "backends.json")
+	if err := os.WriteFile(name, []byte(`{"items": [{"item": "A"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lb := NewCluster(NewRoundRobin())
+	w := NewWatcher(name, lb, 10*time.Millisecond)
+	changed := make(chan struct{})
+	w.OnUpdate = func(choices []*Choice) {
+		if choices[0].Item == "B" {
+			close(changed)
+		}
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// selections run concurrently with the updates of the watcher
+	stop := make(chan struct{})
+	selected := make(chan struct{})
+	go func() {
+		defer close(selected)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				lb.Select()
+			}
+		}
+	}()
+
+	if err := os.WriteFile(name, []byte(`{"items": [{"item": "B"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mt := time.Now().Add(time.Hour)
+	if err := os.Chtimes(name, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not pick up the change")
+	}
+	close(stop)
+	<-selected
+	w.Stop()
+	if item := lb.Select(); item != "B" {
+		t.Fatalf("watcher expected B, actual %s", item)
+	}
+}
@@ -8,38 +8,110 @@ import (
 
 // WeightedRand
 type wr struct {
+	all     []*Choice
 	items   []*Choice
 	weights []int
 	count   int
+	total   int
 	max     uint32
+	src     Source
 }
 
 func NewWeightedRand(choices ...*Choice) (lb *wr) {
-	lb = &wr{}
+	return NewWeightedRandWithSource(nil, choices...)
+}
+
+// NewWeightedRandWithSource create a WeightedRand balancer drawing from src, see NewSource.
+// A nil src uses the default runtime source.
+func NewWeightedRandWithSource(src Source, choices ...*Choice) (lb *wr) {
+	lb = &wr{src: sourceOrDefault(src)}
 	lb.Update(choices)
 	return
 }
 
 func (b *wr) Select(_ ...string) (item interface{}) {
+	item, _ = b.SelectE()
+	return
+}
+
+func (b *wr) SelectE(_ ...string) (interface{}, error) {
+	c, err := b.SelectChoice()
+	if err != nil {
+		return nil, err
+	}
+	return c.Item, nil
+}
+
+func (b *wr) SelectChoice(_ ...string) (*Choice, error) {
 	switch b.count {
 	case 0:
-		item = nil
+		return nil, noChoice(b.total)
 	case 1:
-		item = b.items[0].Item
+		if !b.items[0].Available() {
+			return nil, unavailable(b.items)
+		}
+		return b.items[0], nil
 	default:
-		r := utils.FastRandn(b.max) + 1
+		r := b.src.Uint32n(b.max) + 1
 		i := utils.SearchInts(b.weights, int(r))
-		item = b.items[i].Item
+		if c := b.items[i]; c.Available() {
+			return c, nil
+		}
+		return chooseWeighted(b.items, b.src)
 	}
-	return
+}
+
+func (b *wr) SelectN(n int, key ...string) []interface{} {
+	return choiceItems(b.selectN(n, key))
+}
+
+func (b *wr) selectN(n int, _ []string) []*Choice {
+	return sampleDistinct(n, b.items, b.src, choiceWeight, func() *Choice {
+		c, _ := b.SelectChoice()
+		return c
+	})
+}
+
+// chooseWeighted picks by weight among the available items, the slow path of the weighted random balancers.
+func chooseWeighted(items []*Choice, src Source) (*Choice, error) {
+	max := 0
+	for i := range items {
+		if items[i].Available() {
+			max += items[i].Weight
+		}
+	}
+	if max == 0 {
+		return nil, unavailable(items)
+	}
+
+	r := int(src.Uint32n(uint32(max)))
+	for i := range items {
+		if c := items[i]; c.Available() {
+			if r < c.Weight {
+				return c, nil
+			}
+			r -= c.Weight
+		}
+	}
+	return nil, unavailable(items)
 }
 
 func (b *wr) Name() string {
 	return "WeightedRand"
 }
 
+// Choices returns all items, including those with a weight less than 1.
+func (b *wr) Choices() []*Choice {
+	return b.all
+}
+
 func (b *wr) Update(choices []*Choice) bool {
-	b.items, b.count = cleanWeight(choices)
+	return b.UpdateE(choices) == nil
+}
+
+func (b *wr) UpdateE(choices []*Choice) error {
+	b.all, _ = cleanChoices(choices)
+	b.items, b.count, b.total = cleanWeight(b.all)
 	sort.Slice(b.items, func(i, j int) bool {
 		return b.items[i].Weight < b.items[j].Weight
 	})
@@ -54,5 +126,8 @@ func (b *wr) Update(choices []*Choice) bool {
 	b.weights = weights
 	b.max = uint32(max)
 
-	return true
+	if b.count == 0 {
+		return noChoice(b.total)
+	}
+	return nil
 }
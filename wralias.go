@@ -0,0 +1,143 @@
+package balancer
+
+// WeightedRandAlias is WeightedRand using Vose's alias method:
+// O(1) selection and O(n) Update, at the cost of two random numbers per selection.
+// Ref: https://www.keithschwarz.com/darts-dice-coins/
+type wralias struct {
+	all   []*Choice
+	items []*Choice
+	prob  []uint32
+	alias []uint32
+	count int
+	total int
+	sum   uint32
+	src   Source
+}
+
+func NewWeightedRandAlias(choices ...*Choice) (lb *wralias) {
+	return NewWeightedRandAliasWithSource(nil, choices...)
+}
+
+// NewWeightedRandAliasWithSource create a WeightedRandAlias balancer drawing from src, see NewSource.
+// A nil src uses the default runtime source.
+func NewWeightedRandAliasWithSource(src Source, choices ...*Choice) (lb *wralias) {
+	lb = &wralias{src: sourceOrDefault(src)}
+	lb.Update(choices)
+	return
+}
+
+func (b *wralias) Select(_ ...string) (item interface{}) {
+	item, _ = b.SelectE()
+	return
+}
+
+func (b *wralias) SelectE(_ ...string) (interface{}, error) {
+	c, err := b.SelectChoice()
+	if err != nil {
+		return nil, err
+	}
+	return c.Item, nil
+}
+
+func (b *wralias) SelectChoice(_ ...string) (*Choice, error) {
+	switch b.count {
+	case 0:
+		return nil, noChoice(b.total)
+	case 1:
+		if !b.items[0].Available() {
+			return nil, unavailable(b.items)
+		}
+		return b.items[0], nil
+	default:
+		i := b.src.Uint32n(uint32(b.count))
+		if b.src.Uint32n(b.sum) >= b.prob[i] {
+			i = b.alias[i]
+		}
+		if c := b.items[i]; c.Available() {
+			return c, nil
+		}
+		return chooseWeighted(b.items, b.src)
+	}
+}
+
+func (b *wralias) SelectN(n int, key ...string) []interface{} {
+	return choiceItems(b.selectN(n, key))
+}
+
+func (b *wralias) selectN(n int, _ []string) []*Choice {
+	return sampleDistinct(n, b.items, b.src, choiceWeight, func() *Choice {
+		c, _ := b.SelectChoice()
+		return c
+	})
+}
+
+func (b *wralias) Name() string {
+	return "WeightedRandAlias"
+}
+
+// Choices returns all items, including those with a weight less than 1.
+func (b *wralias) Choices() []*Choice {
+	return b.all
+}
+
+func (b *wralias) Update(choices []*Choice) bool {
+	return b.UpdateE(choices) == nil
+}
+
+func (b *wralias) UpdateE(choices []*Choice) error {
+	b.all, _ = cleanChoices(choices)
+	b.items, b.count, b.total = cleanWeight(b.all)
+
+	sum := 0
+	for i := range b.items {
+		sum += b.items[i].Weight
+	}
+	b.sum = uint32(sum)
+	b.prob, b.alias = buildAlias(b.items, sum)
+
+	if b.count == 0 {
+		return noChoice(b.total)
+	}
+	return nil
+}
+
+// buildAlias builds the alias table in integers: column i keeps item i with probability prob[i] / sum,
+// and gives the rest to item alias[i].
+func buildAlias(items []*Choice, sum int) (prob, alias []uint32) {
+	n := len(items)
+	prob = make([]uint32, n)
+	alias = make([]uint32, n)
+
+	// weights scaled by n, so that the average column is exactly sum
+	scaled := make([]int64, n)
+	small := make([]uint32, 0, n)
+	large := make([]uint32, 0, n)
+	for i := range items {
+		scaled[i] = int64(items[i].Weight) * int64(n)
+		if scaled[i] < int64(sum) {
+			small = append(small, uint32(i))
+		} else {
+			large = append(large, uint32(i))
+		}
+	}
+
+	for len(small) > 0 && len(large) > 0 {
+		l, g := small[len(small)-1], large[len(large)-1]
+		small, large = small[:len(small)-1], large[:len(large)-1]
+
+		prob[l], alias[l] = uint32(scaled[l]), g
+		scaled[g] -= int64(sum) - scaled[l]
+		if scaled[g] < int64(sum) {
+			small = append(small, g)
+		} else {
+			large = append(large, g)
+		}
+	}
+	for _, i := range large {
+		prob[i], alias[i] = uint32(sum), i
+	}
+	for _, i := range small {
+		prob[i], alias[i] = uint32(sum), i
+	}
+	return
+}
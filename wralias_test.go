@@ -0,0 +1,158 @@
+package balancer
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWeightedRandAlias(t *testing.T) {
+	lb := NewWeightedRandAlias()
+	item := lb.Select()
+	if item != nil {
+		t.Fatalf("wralias expected nil, actual %s", item)
+	}
+
+	lb = NewWeightedRandAlias(
+		&Choice{Item: "A", Weight: 0},
+		&Choice{Item: "B", Weight: 1},
+		&Choice{Item: "C", Weight: 7},
+		&Choice{Item: "D", Weight: 2},
+	)
+	count := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		item := lb.Select()
+		count[item.(string)]++
+	}
+	if count["A"] != 0 || count["B"] <= 150 || count["C"] <= 750 || count["D"] <= 250 {
+		t.Fatal("wralias wrong")
+	}
+	if count["A"]+count["B"]+count["C"]+count["D"] != 2000 {
+		t.Fatal("wralias wrong")
+	}
+
+	nodes := []*Choice{
+		{Item: "X", Weight: 0},
+		{Item: "Y", Weight: 1},
+	}
+	ok := lb.Update(nodes)
+	if ok != true {
+		t.Fatal("wralias update wrong")
+	}
+	item = lb.Select()
+	if item != "Y" {
+		t.Fatal("wralias update wrong")
+	}
+	if len(lb.Choices()) != 2 {
+		t.Fatal("wralias choices wrong")
+	}
+}
+
+func TestWeightedRandAlias_Table(t *testing.T) {
+	for _, weights := range [][]int{
+		{1, 1, 1, 1},
+		{5, 3, 1},
+		{1000, 1, 1},
+		{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		{7},
+	} {
+		items := make([]*Choice, len(weights))
+		sum := 0
+		for i, w := range weights {
+			items[i] = &Choice{Item: i, Weight: w}
+			sum += w
+		}
+
+		// the columns must add up to exactly the weights
+		prob, alias := buildAlias(items, sum)
+		share := make([]int64, len(weights))
+		for i := range prob {
+			share[i] += int64(prob[i])
+			share[alias[i]] += int64(sum) - int64(prob[i])
+		}
+		for i, w := range weights {
+			if share[i] != int64(w)*int64(len(weights)) {
+				t.Fatalf("wralias table wrong: %v: %v", weights, share)
+			}
+		}
+	}
+}
+
+func TestWeightedRandAlias_Distribution(t *testing.T) {
+	weights := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 0}
+	choices := make([]*Choice, len(weights))
+	sum := 0
+	for i, w := range weights {
+		choices[i] = &Choice{Item: i, Weight: w}
+		sum += w
+	}
+
+	const n = 110000
+	for _, lb := range []Balancer{
+		NewWeightedRandAliasWithSource(NewSource(1), choices...),
+		NewWeightedRandWithSource(NewSource(1), choices...),
+	} {
+		count := make([]int, len(weights))
+		for i := 0; i < n; i++ {
+			count[lb.Select().(int)]++
+		}
+
+		chi := 0.0
+		for i, w := range weights {
+			if w == 0 {
+				if count[i] != 0 {
+					t.Fatalf("%s selected a zero weight", lb.Name())
+				}
+				continue
+			}
+			expected := float64(n) * float64(w) / float64(sum)
+			d := float64(count[i]) - expected
+			chi += d * d / expected
+		}
+		// 9 degrees of freedom, p = 0.001
+		if chi > 27.88 {
+			t.Fatalf("%s distribution wrong: chi-square %.2f, %v", lb.Name(), chi, count)
+		}
+	}
+}
+
+func TestWeightedRandAlias_C(t *testing.T) {
+	var (
+		a, b, c, d int64
+	)
+	nodes := []*Choice{
+		{Item: "A", Weight: 5},
+		{Item: "B", Weight: 1},
+		{Item: "C", Weight: 4},
+		{Item: "D", Weight: 0},
+	}
+	lb := NewWeightedRandAlias(nodes...)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 500; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				switch lb.Select() {
+				case "A":
+					atomic.AddInt64(&a, 1)
+				case "B":
+					atomic.AddInt64(&b, 1)
+				case "C":
+					atomic.AddInt64(&c, 1)
+				case "D":
+					atomic.AddInt64(&d, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if a+b+c+d != 1000000 || d != 0 {
+		t.Fatal("wralias wrong")
+	}
+	if a <= 450000 || b <= 80000 || c <= 350000 {
+		t.Fatal("wralias wrong")
+	}
+}
@@ -7,12 +7,17 @@ import (
 // Weighted Round-Robin Scheduling
 // Ref: http://kb.linuxvirtualserver.org/wiki/Weighted_Round-Robin_Scheduling
 type wrr struct {
+	all   []*Choice
 	items []*Choice
 	i     int
 	n     int
+	total int
 	cw    int
 	gcd   int
 	max   int
+
+	sched     *schedule
+	schedSize int
 }
 
 func NewWeightedRoundRobin(choices ...*Choice) (lb *wrr) {
@@ -21,16 +26,61 @@ func NewWeightedRoundRobin(choices ...*Choice) (lb *wrr) {
 	return
 }
 
+// NewWeightedRoundRobinWithSchedule create a WeightedRoundRobin balancer that precomputes
+// its cycle of up to size selections on Update, e.g. DefaultScheduleSize.
+// Selection then takes constant time and is goroutine-safe, longer cycles fall back to
+// the regular algorithm, see Scheduled.
+func NewWeightedRoundRobinWithSchedule(size int, choices ...*Choice) (lb *wrr) {
+	lb = &wrr{schedSize: size}
+	lb.Update(choices)
+	return
+}
+
 func (b *wrr) Select(_ ...string) (item interface{}) {
+	item, _ = b.SelectE()
+	return
+}
+
+func (b *wrr) SelectE(_ ...string) (interface{}, error) {
+	c, err := b.SelectChoice()
+	if err != nil {
+		return nil, err
+	}
+	return c.Item, nil
+}
+
+func (b *wrr) SelectChoice(_ ...string) (*Choice, error) {
 	switch b.n {
 	case 0:
-		item = nil
+		return nil, noChoice(b.total)
 	case 1:
-		item = b.items[0].Item
+		if !b.items[0].Available() {
+			return nil, unavailable(b.items)
+		}
+		return b.items[0], nil
 	default:
-		item = b.chooseNext().Item
+		var c *Choice
+		if b.sched != nil {
+			c = b.sched.next(b.items)
+		} else {
+			c = b.chooseNext()
+		}
+		if c == nil {
+			return nil, unavailable(b.items)
+		}
+		return c, nil
 	}
-	return
+}
+
+func (b *wrr) SelectN(n int, key ...string) []interface{} {
+	return choiceItems(b.selectN(n, key))
+}
+
+func (b *wrr) selectN(n int, _ []string) []*Choice {
+	if b.sched != nil {
+		return nextDistinct(n, b.items, func() *Choice { return b.sched.next(b.items) })
+	}
+	return nextDistinct(n, b.items, b.chooseNext)
 }
 
 func (b *wrr) chooseNext() *Choice {
@@ -46,28 +96,55 @@ func (b *wrr) chooseNext() *Choice {
 			}
 		}
 
-		if b.items[b.i].Weight >= b.cw {
-			return b.items[b.i]
+		if c := b.items[b.i]; c.Weight >= b.cw {
+			if c.Available() {
+				return c
+			}
+			if !anyAvailable(b.items) {
+				return nil
+			}
 		}
 	}
 }
 
+// Scheduled reports whether the selections come from a precomputed schedule.
+func (b *wrr) Scheduled() bool {
+	return b.sched != nil
+}
+
 func (b *wrr) Name() string {
 	return "WeightedRoundRobin"
 }
 
+// Choices returns all items, including those with a weight less than 1.
+func (b *wrr) Choices() []*Choice {
+	return b.all
+}
+
 func (b *wrr) Update(choices []*Choice) bool {
-	b.items, b.n = cleanWeight(choices)
+	return b.UpdateE(choices) == nil
+}
+
+func (b *wrr) UpdateE(choices []*Choice) error {
+	b.all, _ = cleanChoices(choices)
+	b.items, b.n, b.total = cleanWeight(b.all)
 	b.i = -1
 	b.cw = 0
 	b.gcd = 0
 	b.max = 0
 
 	for i := range b.items {
-		b.addSettings(choices[i].Weight)
+		b.addSettings(b.items[i].Weight)
+	}
+	b.sched = nil
+	if b.schedSize > 0 {
+		b.sched = newWRRSchedule(b.items, b.gcd, b.max, b.schedSize)
 	}
 
-	return b.n > 0
+	if b.n == 0 {
+		return noChoice(b.total)
+	}
+	return nil
 }
 
 func (b *wrr) addSettings(weight int) {